@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+)
+
+// AnalyzeStat 一组文件的合计
+type AnalyzeStat struct {
+	Count     int64 `json:"count"`
+	Size      int64 `json:"size"`
+	DiskUsage int64 `json:"disk_usage"` // 硬链接只计一次
+}
+
+// AnalyzeBucket 按年龄或大小分段的合计
+type AnalyzeBucket struct {
+	Label string `json:"label"`
+	AnalyzeStat
+}
+
+// ExtStat 某个扩展名的合计
+type ExtStat struct {
+	Ext string `json:"ext"` // 小写，没有扩展名时为空
+	AnalyzeStat
+}
+
+// DeepPath 层级最深的路径
+type DeepPath struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth"` // 相对根目录的层级
+	IsDir bool   `json:"is_dir"`
+}
+
+// AnalyzeReport analyze 子命令的报告
+type AnalyzeReport struct {
+	File        string             `json:"file"`
+	RootPath    string             `json:"root_path"`
+	ScanTime    int64              `json:"scan_time"` // 计算文件年龄的基准时间
+	Files       AnalyzeStat        `json:"files"`
+	Dirs        int64              `json:"dirs"`
+	Sparse      AnalyzeStat        `json:"sparse"`    // 稀疏文件（Size 与 DiskUsage 之差为节省的空间）
+	Hardlinks   AnalyzeStat        `json:"hardlinks"` // 重复的硬链接（DiskUsage 为未重复计算的磁盘占用）
+	BadLines    int64              `json:"bad_lines"`
+	Partial     string             `json:"partial,omitempty"` // 扫描未完整结束时为结束记录中的原因
+	Extensions  []ExtStat          `json:"extensions"`        // 按磁盘占用从大到小
+	AgeBuckets  []AnalyzeBucket    `json:"age_buckets"`
+	SizeBuckets []AnalyzeBucket    `json:"size_buckets"`
+	LargestDirs []scanner.DirTotal `json:"largest_dirs"`
+	DeepestPath []DeepPath         `json:"deepest_paths"`
+}
+
+// 文件年龄分段（按修改时间，上限不含）
+var ageBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"1 天内", 24 * time.Hour},
+	{"1 周内", 7 * 24 * time.Hour},
+	{"1 个月内", 30 * 24 * time.Hour},
+	{"3 个月内", 90 * 24 * time.Hour},
+	{"1 年内", 365 * 24 * time.Hour},
+	{"3 年内", 3 * 365 * 24 * time.Hour},
+	{"3 年以上", 0},
+}
+
+// 文件大小分段（上限不含）
+var sizeBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"0 B", 1},
+	{"< 4 KB", 4 * 1024},
+	{"4 KB - 64 KB", 64 * 1024},
+	{"64 KB - 1 MB", 1024 * 1024},
+	{"1 MB - 16 MB", 16 * 1024 * 1024},
+	{"16 MB - 256 MB", 256 * 1024 * 1024},
+	{"256 MB - 1 GB", 1024 * 1024 * 1024},
+	{"1 GB - 16 GB", 16 * 1024 * 1024 * 1024},
+	{"≥ 16 GB", 0},
+}
+
+// analyzer 流式统计一个扫描结果文件
+type analyzer struct {
+	report  *AnalyzeReport
+	now     time.Time
+	exts    map[string]*ExtStat
+	dirs    map[string]*scanner.DirTotal // 目录路径 -> 递归合计（累加到所有上级目录）
+	deepest *scanner.TopN[DeepPath]
+}
+
+// runAnalyze analyze 子命令：离线统计扫描结果
+func runAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "以 JSON 格式输出到标准输出")
+	reportPath := fs.String("o", "", "JSON 报告输出路径")
+	topCount := fs.Int("top", 20, "扩展名、目录和最深路径显示的条数")
+	nowStr := fs.String("now", "", "计算文件年龄的基准时间（2006-01-02 或 2006-01-02 15:04），默认为输出文件头中的开始时间")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s analyze [选项] <扫描结果.jsonl>\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "离线统计扫描结果：按扩展名、文件年龄和大小分段的数量与占用，最大的目录，稀疏文件和硬链接合计，以及层级最深的路径。")
+		fmt.Fprintln(fs.Output(), "只读取输出文件，不需要重新扫描，可以在其他机器上分析。")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var now time.Time
+	if *nowStr != "" {
+		t, err := scanner.ParseLocalTime(*nowStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-now 参数错误: %v\n", err)
+			os.Exit(2)
+		}
+		now = t
+	}
+
+	report, err := analyzeScan(fs.Arg(0), *topCount, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "分析失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput || *reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成报告失败: %v\n", err)
+			os.Exit(1)
+		}
+		data = append(data, '\n')
+		if *jsonOutput {
+			os.Stdout.Write(data)
+		}
+		if *reportPath != "" {
+			if err := os.WriteFile(*reportPath, data, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "无法写入报告: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if !*jsonOutput {
+		printAnalyzeReport(report, *topCount)
+		if *reportPath != "" {
+			fmt.Printf("📝 分析报告: %s\n", *reportPath)
+		}
+	}
+}
+
+// analyzeScan 统计扫描结果，now 为零值时使用输出文件头中的开始时间
+func analyzeScan(path string, topCount int, now time.Time) (*AnalyzeReport, error) {
+	if now.IsZero() {
+		if t, ok := scanStartTime(path); ok {
+			now = t
+		} else {
+			now = time.Now()
+		}
+	}
+
+	a := &analyzer{
+		report: &AnalyzeReport{File: path, ScanTime: now.Unix()},
+		now:    now,
+		exts:   make(map[string]*ExtStat),
+		dirs:   make(map[string]*scanner.DirTotal),
+		deepest: scanner.NewTopN(topCount, func(x, y DeepPath) bool {
+			if x.Depth != y.Depth {
+				return x.Depth < y.Depth
+			}
+			return x.Path > y.Path
+		}),
+	}
+	for _, b := range ageBuckets {
+		a.report.AgeBuckets = append(a.report.AgeBuckets, AnalyzeBucket{Label: b.label})
+	}
+	for _, b := range sizeBuckets {
+		a.report.SizeBuckets = append(a.report.SizeBuckets, AnalyzeBucket{Label: b.label})
+	}
+
+	badLines, err := scanner.ReadScanRecords(path, func(rec *scanner.ScanRecord) error {
+		a.add(rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.report.BadLines = badLines
+	if summary, err := scanner.ReadScanSummary(path); err == nil && summary != nil && !summary.Complete {
+		a.report.Partial = summary.Reason
+	}
+	a.finish(topCount)
+	return a.report, nil
+}
+
+// add 登记一条记录
+func (a *analyzer) add(rec *scanner.ScanRecord) {
+	a.report.RootPath = scanner.WidenRoot(a.report.RootPath, rec.Path)
+	a.deepest.Push(DeepPath{Path: rec.Path, Depth: strings.Count(rec.Path, "/"), IsDir: rec.IsDir})
+
+	if rec.IsDir {
+		a.report.Dirs++
+		d := a.dir(rec.Path)
+		d.ModTime = rec.ModTime
+		for p := filepath.Dir(rec.Path); ; p = filepath.Dir(p) {
+			a.dir(p).DirCount++
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+		return
+	}
+
+	disk := recordDisk(rec)
+	a.report.Files.add(rec.Size, disk)
+	if rec.IsSparse {
+		a.report.Sparse.add(rec.Size, rec.DiskUsage)
+	}
+	if rec.IsHardlink {
+		a.report.Hardlinks.add(rec.Size, rec.DiskUsage)
+	}
+
+	ext := strings.ToLower(filepath.Ext(rec.Name))
+	if ext == strings.ToLower(rec.Name) {
+		// .bashrc 这类以点开头的文件名不算扩展名
+		ext = ""
+	}
+	e, ok := a.exts[ext]
+	if !ok {
+		e = &ExtStat{Ext: ext}
+		a.exts[ext] = e
+	}
+	e.add(rec.Size, disk)
+
+	age := a.now.Sub(time.Unix(rec.ModTime, 0))
+	for i, b := range ageBuckets {
+		if b.max == 0 || age < b.max {
+			a.report.AgeBuckets[i].add(rec.Size, disk)
+			break
+		}
+	}
+	for i, b := range sizeBuckets {
+		if b.max == 0 || rec.Size < b.max {
+			a.report.SizeBuckets[i].add(rec.Size, disk)
+			break
+		}
+	}
+
+	for p := filepath.Dir(rec.Path); ; p = filepath.Dir(p) {
+		d := a.dir(p)
+		d.Size += rec.Size
+		d.DiskUsage += disk
+		d.FileCount++
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+}
+
+// dir 获取目录的合计，不存在时创建
+func (a *analyzer) dir(path string) *scanner.DirTotal {
+	d, ok := a.dirs[path]
+	if !ok {
+		d = &scanner.DirTotal{Path: path}
+		a.dirs[path] = d
+	}
+	return d
+}
+
+func (s *AnalyzeStat) add(size, disk int64) {
+	s.Count++
+	s.Size += size
+	s.DiskUsage += disk
+}
+
+// finish 生成扩展名、目录和最深路径排行
+func (a *analyzer) finish(topCount int) {
+	r := a.report
+
+	r.Extensions = make([]ExtStat, 0, len(a.exts))
+	for _, e := range a.exts {
+		r.Extensions = append(r.Extensions, *e)
+	}
+	sort.Slice(r.Extensions, func(i, j int) bool {
+		if r.Extensions[i].DiskUsage != r.Extensions[j].DiskUsage {
+			return r.Extensions[i].DiskUsage > r.Extensions[j].DiskUsage
+		}
+		return r.Extensions[i].Ext < r.Extensions[j].Ext
+	})
+
+	topDirs := scanner.NewTopN(topCount, func(x, y *scanner.DirTotal) bool {
+		if x.DiskUsage != y.DiskUsage {
+			return x.DiskUsage < y.DiskUsage
+		}
+		return x.Path > y.Path
+	})
+	for path, d := range a.dirs {
+		// 根目录就是总计，根目录之上的目录与根目录相同，都不参与排行
+		if path == r.RootPath || !scanner.IsPathWithin(path, r.RootPath) {
+			continue
+		}
+		topDirs.Push(d)
+	}
+	r.LargestDirs = make([]scanner.DirTotal, 0, topCount)
+	for _, d := range topDirs.Sorted() {
+		r.LargestDirs = append(r.LargestDirs, *d)
+	}
+
+	rootDepth := strings.Count(r.RootPath, "/")
+	if r.RootPath == "/" {
+		rootDepth = 0
+	}
+	r.DeepestPath = a.deepest.Sorted()
+	for i := range r.DeepestPath {
+		r.DeepestPath[i].Depth -= rootDepth
+	}
+}
+
+// scanStartTime 读取输出文件头中的开始时间（兼容旧版本的 # 注释文件头）
+func scanStartTime(path string) (time.Time, bool) {
+	if header, err := scanner.ReadScanHeader(path); err == nil && header != nil {
+		t, err := time.Parse(time.RFC3339, header.StartTime)
+		return t, err == nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 10 && scanner.Scan(); i++ {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "# 开始时间: "); ok {
+			t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+			return t, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// printAnalyzeReport 显示分析结果
+func printAnalyzeReport(r *AnalyzeReport, topCount int) {
+	fmt.Println("════════════════════════════════════════")
+	fmt.Println("📊 扫描结果分析")
+	fmt.Println("════════════════════════════════════════")
+	fmt.Printf("文件: %s\n", r.File)
+	fmt.Printf("根目录: %s\n", r.RootPath)
+	fmt.Printf("📁 目录数: %s\n", scanner.FormatNumber(r.Dirs))
+	fmt.Printf("📄 文件数: %s (%s, 磁盘占用 %s)\n", scanner.FormatNumber(r.Files.Count), scanner.FormatSize(r.Files.Size), scanner.FormatSize(r.Files.DiskUsage))
+	if r.Sparse.Count > 0 {
+		fmt.Printf("🕳️  稀疏文件: %s 个, %s, 实际占用 %s\n", scanner.FormatNumber(r.Sparse.Count), scanner.FormatSize(r.Sparse.Size), scanner.FormatSize(r.Sparse.DiskUsage))
+	}
+	if r.Hardlinks.Count > 0 {
+		fmt.Printf("🔗 硬链接: %s 个重复, 未重复计算 %s\n", scanner.FormatNumber(r.Hardlinks.Count), scanner.FormatSize(r.Hardlinks.DiskUsage))
+	}
+	if r.BadLines > 0 {
+		fmt.Printf("⚠️  %d 行无法解析，已忽略\n", r.BadLines)
+	}
+	if r.Partial != "" {
+		fmt.Printf("⚠️  扫描未完整结束（%s），结果只包含部分文件\n", r.Partial)
+	}
+	fmt.Println("════════════════════════════════════════")
+
+	fmt.Printf("\n按扩展名 (前 %d，磁盘占用):\n", topCount)
+	for i, e := range r.Extensions {
+		if i >= topCount {
+			break
+		}
+		ext := e.Ext
+		if ext == "" {
+			ext = "(无扩展名)"
+		}
+		fmt.Printf("  %12s  %12s 个  %s\n", scanner.FormatSize(e.DiskUsage), scanner.FormatNumber(e.Count), ext)
+	}
+
+	fmt.Printf("\n按修改时间 (基准 %s):\n", time.Unix(r.ScanTime, 0).Format("2006-01-02 15:04"))
+	printAnalyzeBuckets(r.AgeBuckets)
+
+	fmt.Println("\n按文件大小:")
+	printAnalyzeBuckets(r.SizeBuckets)
+
+	if len(r.LargestDirs) > 0 {
+		fmt.Printf("\n占用空间最大的目录 (前 %d):\n", topCount)
+		for _, d := range r.LargestDirs {
+			fmt.Printf("  %12s  %s (%s 个文件)\n", scanner.FormatSize(d.DiskUsage), d.Path, scanner.FormatNumber(d.FileCount))
+		}
+	}
+
+	if len(r.DeepestPath) > 0 {
+		fmt.Printf("\n层级最深的路径 (前 %d):\n", topCount)
+		for _, p := range r.DeepestPath {
+			fmt.Printf("  %4d  %s\n", p.Depth, p.Path)
+		}
+	}
+	fmt.Println()
+}
+
+// printAnalyzeBuckets 显示分段统计
+func printAnalyzeBuckets(buckets []AnalyzeBucket) {
+	for _, b := range buckets {
+		fmt.Printf("  %-16s %12s 个  %12s  磁盘占用 %s\n", b.Label, scanner.FormatNumber(b.Count), scanner.FormatSize(b.Size), scanner.FormatSize(b.DiskUsage))
+	}
+}
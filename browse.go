@@ -0,0 +1,577 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+	"golang.org/x/term"
+)
+
+// 浏览界面的排序方式
+const (
+	browseSortDisk  = iota // 按磁盘占用
+	browseSortSize         // 按逻辑大小
+	browseSortName         // 按名称
+	browseSortItems        // 按项数
+	browseSortCount
+)
+
+var browseSortNames = []string{"磁盘占用", "逻辑大小", "名称", "项数"}
+
+// runBrowse browse 子命令：在全屏终端界面中浏览扫描结果
+func runBrowse(args []string) {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	rootPath := fs.String("path", "", "实时扫描并浏览的目录（不指定时浏览扫描结果文件）")
+	workers := fs.Int("workers", runtime.NumCPU()*4, "实时扫描的并发工作协程数")
+	excludePaths := fs.String("exclude", "", "实时扫描时要排除的路径，多个路径用逗号分隔")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s browse [选项] <扫描结果.jsonl>\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "      %s browse -path <目录> [选项]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "在全屏终端界面中按大小浏览目录，可以打开保存的扫描结果，也可以边扫描边浏览。")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	b := &browser{}
+	switch {
+	case *rootPath != "" && fs.NArg() == 0:
+		absPath, err := filepath.Abs(*rootPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "路径错误: %v\n", err)
+			os.Exit(1)
+		}
+		if info, err := os.Stat(absPath); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "%s 不是一个可访问的目录\n", absPath)
+			os.Exit(1)
+		}
+		if err := b.startScan(scanner.Options{
+			RootPath:     absPath,
+			WorkerCount:  *workers,
+			ExcludePaths: parseExcludePaths(*excludePaths),
+			BuildTree:    true,
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	case *rootPath == "" && fs.NArg() == 1:
+		fmt.Printf("📖 正在加载 %s ...\n", fs.Arg(0))
+		root, err := loadScanTree(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载失败: %v\n", err)
+			os.Exit(1)
+		}
+		b.root = root
+		b.source = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := b.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "浏览失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadScanTree 从扫描结果文件构建文件树并汇总目录大小
+// 根节点是所有记录的公共上级目录
+func loadScanTree(path string) (*scanner.FileNode, error) {
+	dirs := make(map[string]*scanner.FileNode)
+	var ensureDir func(dirPath string) *scanner.FileNode
+	ensureDir = func(dirPath string) *scanner.FileNode {
+		if node, ok := dirs[dirPath]; ok {
+			return node
+		}
+		node := &scanner.FileNode{Path: dirPath, Name: filepath.Base(dirPath), IsDir: true}
+		dirs[dirPath] = node
+		if parentPath := filepath.Dir(dirPath); parentPath != dirPath {
+			parent := ensureDir(parentPath)
+			parent.Children = append(parent.Children, node)
+		}
+		return node
+	}
+
+	var rootPath string
+	var count int64
+	_, err := scanner.ReadScanRecords(path, func(rec *scanner.ScanRecord) error {
+		rootPath = scanner.WidenRoot(rootPath, rec.Path)
+		count++
+		if rec.IsDir {
+			ensureDir(rec.Path).ModTime = rec.ModTime
+			return nil
+		}
+		parent := ensureDir(filepath.Dir(rec.Path))
+		parent.Children = append(parent.Children, &scanner.FileNode{
+			Path:       rec.Path,
+			Name:       rec.Name,
+			Size:       rec.Size,
+			DiskUsage:  rec.DiskUsage,
+			ModTime:    rec.ModTime,
+			IsSparse:   rec.IsSparse,
+			IsHardlink: rec.IsHardlink,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%s 中没有扫描记录", path)
+	}
+
+	root := ensureDir(rootPath)
+	scanner.RollupTree(root)
+	return root, nil
+}
+
+// browseEntry 当前目录中的一个子项
+type browseEntry struct {
+	node  *scanner.FileNode
+	size  int64
+	disk  int64
+	items int64 // 目录下的项数（递归的文件数 + 子目录数）
+}
+
+// browseLevel 进入子目录前的位置，返回时恢复
+type browseLevel struct {
+	dir    *scanner.FileNode
+	cursor int
+	offset int
+}
+
+// browser 全屏浏览界面的状态
+type browser struct {
+	root    *scanner.FileNode
+	source  string           // 扫描结果文件（浏览保存的结果时）
+	scanner *scanner.Scanner // 实时扫描（边扫描边浏览时）
+	done    chan struct{}    // 实时扫描结束时关闭
+	scanErr error
+
+	cur     *scanner.FileNode
+	stack   []browseLevel
+	entries []browseEntry
+	total   browseEntry // 当前目录的合计
+	cursor  int
+	offset  int
+	sortBy  int
+}
+
+// startScan 在后台开始实时扫描
+func (b *browser) startScan(options scanner.Options) error {
+	s, err := scanner.New(options)
+	if err != nil {
+		return err
+	}
+	b.scanner = s
+	b.root = s.GetFileTree()
+	b.done = make(chan struct{})
+	go func() {
+		_, b.scanErr = s.Scan(context.Background())
+		close(b.done)
+	}()
+	return nil
+}
+
+// scanning 实时扫描是否仍在进行
+func (b *browser) scanning() bool {
+	if b.done == nil {
+		return false
+	}
+	select {
+	case <-b.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// run 进入全屏界面，按 q 退出
+func (b *browser) run() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("browse 需要在终端中运行")
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	// 切换到备用屏幕并隐藏光标，退出时恢复
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	// 扫描过程中定期刷新目录大小
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	b.cur = b.root
+	b.refresh()
+	for {
+		b.render()
+		select {
+		case key, ok := <-keys:
+			if !ok || !b.handleKey(key) {
+				return nil
+			}
+		case <-winch:
+		case <-ticker.C:
+			if b.scanning() {
+				b.refresh()
+			}
+		case <-b.done:
+			b.done = nil // 只刷新一次
+			b.refresh()
+		}
+	}
+}
+
+// handleKey 处理按键，返回 false 表示退出
+func (b *browser) handleKey(key string) bool {
+	page := b.listHeight()
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "up", "k":
+		b.cursor--
+	case "down", "j":
+		b.cursor++
+	case "pgup":
+		b.cursor -= page
+	case "pgdn":
+		b.cursor += page
+	case "home", "g":
+		b.cursor = 0
+	case "end", "G":
+		b.cursor = len(b.entries) - 1
+	case "right", "l", "enter":
+		if b.cursor < len(b.entries) && b.entries[b.cursor].node.IsDir {
+			b.stack = append(b.stack, browseLevel{dir: b.cur, cursor: b.cursor, offset: b.offset})
+			b.cur = b.entries[b.cursor].node
+			b.cursor, b.offset = 0, 0
+			b.refresh()
+		}
+	case "left", "h", "backspace":
+		if len(b.stack) > 0 {
+			level := b.stack[len(b.stack)-1]
+			b.stack = b.stack[:len(b.stack)-1]
+			b.cur, b.cursor, b.offset = level.dir, level.cursor, level.offset
+			b.refresh()
+		}
+	case "s":
+		b.sortBy = (b.sortBy + 1) % browseSortCount
+		b.refresh()
+	}
+	b.clampCursor()
+	return true
+}
+
+// refresh 重新读取当前目录的子项并排序，光标保持在原来的子项上
+func (b *browser) refresh() {
+	var selected *scanner.FileNode
+	if b.cursor >= 0 && b.cursor < len(b.entries) {
+		selected = b.entries[b.cursor].node
+	}
+
+	children := b.cur.ChildNodes()
+
+	// 扫描进行中时目录节点还没有汇总，临时计算（只读，不修改节点）
+	live := b.scanning()
+	b.entries = b.entries[:0]
+	b.total = browseEntry{node: b.cur}
+	for _, child := range children {
+		e := browseEntry{node: child}
+		switch {
+		case !child.IsDir:
+			e.size, e.items = child.Size, 0
+			if !child.IsHardlink {
+				e.disk = child.DiskUsage
+			}
+		case live:
+			e.size, e.disk, e.items = subtreeTotals(child)
+		default:
+			e.size, e.disk, e.items = child.Size, child.DiskUsage, child.FileCount+child.DirCount
+		}
+		b.entries = append(b.entries, e)
+		b.total.size += e.size
+		b.total.disk += e.disk
+		b.total.items += e.items + 1
+	}
+
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		x, y := b.entries[i], b.entries[j]
+		switch b.sortBy {
+		case browseSortSize:
+			return x.size > y.size
+		case browseSortName:
+			return x.node.Name < y.node.Name
+		case browseSortItems:
+			return x.items > y.items
+		default:
+			return x.disk > y.disk
+		}
+	})
+
+	if selected != nil {
+		for i, e := range b.entries {
+			if e.node == selected {
+				b.cursor = i
+				break
+			}
+		}
+	}
+	b.clampCursor()
+}
+
+// subtreeTotals 计算目录的递归合计（扫描进行中使用）
+func subtreeTotals(node *scanner.FileNode) (size, disk, items int64) {
+	for _, child := range node.ChildNodes() {
+		items++
+		if child.IsDir {
+			s, d, n := subtreeTotals(child)
+			size += s
+			disk += d
+			items += n
+			continue
+		}
+		size += child.Size
+		if !child.IsHardlink {
+			disk += child.DiskUsage
+		}
+	}
+	return size, disk, items
+}
+
+func (b *browser) clampCursor() {
+	if b.cursor >= len(b.entries) {
+		b.cursor = len(b.entries) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	page := b.listHeight()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+page {
+		b.offset = b.cursor - page + 1
+	}
+	if b.offset < 0 {
+		b.offset = 0
+	}
+}
+
+// terminalSize 返回终端宽高，获取失败时使用 80x24
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// listHeight 列表区域的行数（去掉标题、路径、合计和帮助四行）
+func (b *browser) listHeight() int {
+	_, height := terminalSize()
+	if height-4 < 1 {
+		return 1
+	}
+	return height - 4
+}
+
+// render 重绘整个屏幕（raw 模式下换行需要 \r\n）
+func (b *browser) render() {
+	width, _ := terminalSize()
+	var sb strings.Builder
+	sb.WriteString("\033[H\033[2J")
+
+	// 标题
+	var status string
+	var dirs, files int64
+	if b.scanner != nil {
+		dirs, files = b.scanner.Counts()
+	}
+	switch {
+	case b.scanner != nil && b.scanning():
+		status = fmt.Sprintf("扫描中... 目录 %s  文件 %s", scanner.FormatNumber(dirs), scanner.FormatNumber(files))
+	case b.scanner != nil && b.scanErr != nil:
+		status = fmt.Sprintf("扫描失败: %v", b.scanErr)
+	case b.scanner != nil:
+		status = fmt.Sprintf("扫描完成  目录 %s  文件 %s", scanner.FormatNumber(dirs), scanner.FormatNumber(files))
+	default:
+		status = b.source
+	}
+	sb.WriteString("\033[7m")
+	sb.WriteString(padLine(" mac-file-search browse | "+status, width))
+	sb.WriteString("\033[0m\r\n")
+
+	// 当前路径
+	sb.WriteString(padLine(" --- "+b.cur.Path+" ", width))
+	sb.WriteString("\r\n")
+
+	// 子项列表
+	page := b.listHeight()
+	for i := b.offset; i < b.offset+page; i++ {
+		if i >= len(b.entries) {
+			sb.WriteString("\r\n")
+			continue
+		}
+		line := b.formatEntry(b.entries[i], width)
+		if i == b.cursor {
+			sb.WriteString("\033[7m" + line + "\033[0m")
+		} else {
+			sb.WriteString(line)
+		}
+		sb.WriteString("\r\n")
+	}
+
+	// 合计和帮助
+	sb.WriteString(padLine(fmt.Sprintf(" 合计: 磁盘占用 %s  逻辑大小 %s  %s 项  排序: %s",
+		scanner.FormatSize(b.total.disk), scanner.FormatSize(b.total.size), scanner.FormatNumber(b.total.items), browseSortNames[b.sortBy]), width))
+	sb.WriteString("\r\n\033[7m")
+	sb.WriteString(padLine(" ↑↓ 移动  →/回车 进入  ←/退格 返回  s 切换排序  q 退出", width))
+	sb.WriteString("\033[0m")
+
+	os.Stdout.WriteString(sb.String())
+}
+
+// formatEntry 格式化一行子项：大小、百分比、比例条、项数、标记和名称
+// 标记：H 硬链接（重复的 inode，不计入磁盘占用），S 稀疏文件
+func (b *browser) formatEntry(e browseEntry, width int) string {
+	value, total := e.disk, b.total.disk
+	switch b.sortBy {
+	case browseSortSize:
+		value, total = e.size, b.total.size
+	case browseSortItems:
+		value, total = e.items, b.total.items
+	}
+	percent := 0.0
+	if total > 0 {
+		percent = float64(value) / float64(total) * 100
+	}
+	bar := strings.Repeat("#", int(percent/10+0.5))
+
+	sizeStr := scanner.FormatSize(e.disk)
+	if b.sortBy == browseSortSize {
+		sizeStr = scanner.FormatSize(e.size)
+	}
+
+	items := ""
+	if e.node.IsDir {
+		items = scanner.FormatNumber(e.items)
+	}
+
+	marker := ' '
+	switch {
+	case e.node.IsHardlink:
+		marker = 'H'
+	case e.node.IsSparse:
+		marker = 'S'
+	}
+
+	name := e.node.Name
+	if e.node.IsDir {
+		name += "/"
+	}
+
+	return padLine(fmt.Sprintf(" %10s %5.1f%% [%-10s] %9s %c %s", sizeStr, percent, bar, items, marker, name), width)
+}
+
+// padLine 截断或补齐到终端宽度（按东亚字符占两列估算）
+func padLine(s string, width int) string {
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		w := 1
+		if r >= 0x1100 {
+			w = 2
+		}
+		if used+w > width {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	if used < width {
+		sb.WriteString(strings.Repeat(" ", width-used))
+	}
+	return sb.String()
+}
+
+// readKeys 读取按键并转换为按键名称，输入结束时关闭 keys
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys 解析一次读取到的输入（可能包含多个按键和转义序列）
+func parseKeys(data []byte) []string {
+	escapes := map[string]string{
+		"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+		"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+		"[5~": "pgup", "[6~": "pgdn",
+		"[H": "home", "[1~": "home", "OH": "home",
+		"[F": "end", "[4~": "end", "OF": "end",
+	}
+
+	var keys []string
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == 0x1b:
+			// 转义序列：ESC [ 或 ESC O 开头，以字母或 ~ 结束
+			j := i + 1
+			if j < len(data) && (data[j] == '[' || data[j] == 'O') {
+				j++
+				for j < len(data) && !((data[j] >= 'A' && data[j] <= 'Z') || (data[j] >= 'a' && data[j] <= 'z') || data[j] == '~') {
+					j++
+				}
+				if j < len(data) {
+					if key, ok := escapes[string(data[i+1:j+1])]; ok {
+						keys = append(keys, key)
+					}
+					i = j
+					continue
+				}
+			}
+			keys = append(keys, "esc")
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == 127 || c == 8:
+			keys = append(keys, "backspace")
+		case c == 3:
+			keys = append(keys, "ctrl-c")
+		default:
+			keys = append(keys, string(c))
+		}
+	}
+	return keys
+}
@@ -27,11 +27,14 @@ echo ""
 # 统计信息
 echo "统计信息:"
 TOTAL_LINES=$(wc -l < "$SCAN_FILE")
-DATA_LINES=$(grep -v '^#' "$SCAN_FILE" | wc -l)
-DIR_COUNT=$(grep -v '^#' "$SCAN_FILE" | jq -r 'select(.is_dir==true)' | wc -l)
-FILE_COUNT=$(grep -v '^#' "$SCAN_FILE" | jq -r 'select(.is_dir==false)' | wc -l)
-TOTAL_SIZE=$(grep -v '^#' "$SCAN_FILE" | jq -s 'map(select(.is_dir==false) | .size) | add // 0')
+# 文件头、目录汇总和结束记录带 type 字段，不计入数据行（旧版本的文件头为 # 注释行）
+DATA_LINES=$(grep -v '^#' "$SCAN_FILE" | jq -c 'select(.type==null)' | wc -l)
+DIR_COUNT=$(grep -v '^#' "$SCAN_FILE" | jq -c 'select(.type==null and .is_dir==true)' | wc -l)
+FILE_COUNT=$(grep -v '^#' "$SCAN_FILE" | jq -c 'select(.type==null and .is_dir==false)' | wc -l)
+TOTAL_SIZE=$(grep -v '^#' "$SCAN_FILE" | jq -s 'map(select(.type==null and .is_dir==false) | .size) | add // 0')
+SCAN_STATUS=$(tail -n 1 "$SCAN_FILE" | jq -r 'select(.type=="scan_summary") | if .complete then "完整" else "未完成（\(.reason)）" end' 2>/dev/null)
 
+echo "  扫描状态: ${SCAN_STATUS:-未知（没有结束记录，扫描可能仍在进行或被强制结束）}"
 echo "  总行数: $TOTAL_LINES"
 echo "  数据行: $DATA_LINES"
 echo "  目录数: $DIR_COUNT"
@@ -56,7 +59,7 @@ echo ""
 # 查找最大的10个文件
 echo "最大的10个文件:"
 grep -v '^#' "$SCAN_FILE" | \
-    jq -r 'select(.is_dir==false) | "\(.size)\t\(.path)"' | \
+    jq -r 'select(.type==null and .is_dir==false) | "\(.size)\t\(.path)"' | \
     sort -rn | \
     head -10 | \
     while IFS=$'\t' read -r size path; do
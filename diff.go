@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+)
+
+// 变化类型
+const (
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeGrown    = "grown"
+	changeShrunk   = "shrunk"
+	changeModified = "modified"
+)
+
+// DiffChange 单个文件的变化
+type DiffChange struct {
+	Change    string `json:"change"`
+	Path      string `json:"path"`
+	PathBytes []byte `json:"path_bytes,omitempty"` // 路径不是有效的 UTF-8 时的原始字节，与扫描结果的记录一致
+	OldSize   int64  `json:"old_size"`
+	NewSize   int64  `json:"new_size"`
+	SizeDelta int64  `json:"size_delta"`
+	DiskDelta int64  `json:"disk_delta"`
+}
+
+// DiffStat 某一类变化的汇总
+type DiffStat struct {
+	Count     int64 `json:"count"`
+	SizeDelta int64 `json:"size_delta"`
+	DiskDelta int64 `json:"disk_delta"`
+}
+
+// DirChange 目录（含所有子目录）的净变化
+type DirChange struct {
+	Path      string `json:"path"`
+	SizeDelta int64  `json:"size_delta"`
+	DiskDelta int64  `json:"disk_delta"`
+}
+
+// DiffReport 两次扫描的对比报告
+type DiffReport struct {
+	OldFile     string                  `json:"old_file"`
+	NewFile     string                  `json:"new_file"`
+	RootPath    string                  `json:"root_path"`
+	Added       DiffStat                `json:"added"`
+	Removed     DiffStat                `json:"removed"`
+	Grown       DiffStat                `json:"grown"`
+	Shrunk      DiffStat                `json:"shrunk"`
+	Modified    DiffStat                `json:"modified"`
+	AddedDirs   int64                   `json:"added_dirs"`
+	RemovedDirs int64                   `json:"removed_dirs"`
+	SizeDelta   int64                   `json:"size_delta"`
+	DiskDelta   int64                   `json:"disk_delta"`
+	TopDirs     []DirChange             `json:"top_dirs"`
+	TopChanges  map[string][]DiffChange `json:"top_changes"` // 每类变化中幅度最大的文件
+}
+
+// differ 对两个按路径排序的记录流做归并比较
+type differ struct {
+	report    *DiffReport
+	dirDeltas map[string]*DirChange                // 目录路径 -> 净变化（累加到所有上级目录）
+	top       map[string]*scanner.TopN[DiffChange] // 每类变化幅度最大的文件
+	changes   *bufio.Writer                        // 逐条输出变化（可选）
+	rootPath  string                               // 所有记录的公共上级目录
+}
+
+// runDiff diff 子命令：比较同一根目录的两次扫描结果
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	reportPath := fs.String("o", "", "JSON 报告输出路径")
+	changesPath := fs.String("changes", "", "将每一项文件变化写入 JSON Lines 文件")
+	topCount := fs.Int("top", 20, "报告中每类变化和目录变化显示的条数")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s diff [选项] <旧扫描结果.jsonl> <新扫描结果.jsonl>\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "比较同一根目录的两次扫描结果，报告新增、删除、增大、减小和修改的文件，以及各目录的净变化。")
+		fmt.Fprintln(fs.Output(), "输入文件不需要预先排序，已按路径排序（-sorted）时可以省去排序开销。")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := diffScans(fs.Arg(0), fs.Arg(1), *topCount, *changesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "对比失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	printDiffReport(report, *topCount)
+
+	if *reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成报告失败: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(*reportPath, append(data, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "无法写入报告: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📝 对比报告: %s\n", *reportPath)
+	}
+}
+
+// diffScans 比较两次扫描结果
+// 两个文件都按路径顺序流式读取（无序时先做外部排序），内存占用与文件大小无关
+func diffScans(oldPath, newPath string, topCount int, changesPath string) (*DiffReport, error) {
+	oldIt, err := scanner.SortedScanLines(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	defer oldIt.Close()
+
+	newIt, err := scanner.SortedScanLines(newPath)
+	if err != nil {
+		return nil, err
+	}
+	defer newIt.Close()
+
+	d := &differ{
+		report:    &DiffReport{OldFile: oldPath, NewFile: newPath, TopChanges: make(map[string][]DiffChange)},
+		dirDeltas: make(map[string]*DirChange),
+		top:       make(map[string]*scanner.TopN[DiffChange]),
+	}
+	for _, kind := range []string{changeAdded, changeRemoved, changeGrown, changeShrunk, changeModified} {
+		d.top[kind] = scanner.NewTopN(topCount, func(a, b DiffChange) bool {
+			return absInt64(a.SizeDelta) < absInt64(b.SizeDelta)
+		})
+	}
+
+	if changesPath != "" {
+		f, err := os.Create(changesPath)
+		if err != nil {
+			return nil, fmt.Errorf("无法创建变化列表文件: %v", err)
+		}
+		defer f.Close()
+		d.changes = bufio.NewWriter(f)
+		defer d.changes.Flush()
+	}
+
+	// 归并：两边按路径有序，路径相同的记录进行比较
+	oldLine, oldOK, err := oldIt.Next()
+	if err != nil {
+		return nil, err
+	}
+	newLine, newOK, err := newIt.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	for oldOK || newOK {
+		switch {
+		case !newOK || (oldOK && oldLine.Path < newLine.Path):
+			if err := d.compare(oldLine.Line, nil); err != nil {
+				return nil, err
+			}
+			if oldLine, oldOK, err = oldIt.Next(); err != nil {
+				return nil, err
+			}
+		case !oldOK || newLine.Path < oldLine.Path:
+			if err := d.compare(nil, newLine.Line); err != nil {
+				return nil, err
+			}
+			if newLine, newOK, err = newIt.Next(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := d.compare(oldLine.Line, newLine.Line); err != nil {
+				return nil, err
+			}
+			if oldLine, oldOK, err = oldIt.Next(); err != nil {
+				return nil, err
+			}
+			if newLine, newOK, err = newIt.Next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	d.finish(topCount)
+	return d.report, nil
+}
+
+// compare 比较同一路径的新旧记录（其中一个为 nil 表示新增或删除）
+func (d *differ) compare(oldLine, newLine []byte) error {
+	var oldRec, newRec *scanner.ScanRecord
+	var err error
+	if oldLine != nil {
+		if oldRec, err = scanner.ParseScanRecord(oldLine); err != nil {
+			return nil
+		}
+		d.trackRoot(oldRec.Path)
+	}
+	if newLine != nil {
+		if newRec, err = scanner.ParseScanRecord(newLine); err != nil {
+			return nil
+		}
+		d.trackRoot(newRec.Path)
+	}
+
+	// 目录只统计增删，大小变化通过文件累加到目录
+	if (oldRec != nil && oldRec.IsDir) || (newRec != nil && newRec.IsDir) {
+		switch {
+		case oldRec == nil || !oldRec.IsDir:
+			d.report.AddedDirs++
+		case newRec == nil || !newRec.IsDir:
+			d.report.RemovedDirs++
+		}
+		// 文件和目录互相替换时，文件的一侧按增删处理
+		if oldRec != nil && !oldRec.IsDir {
+			return d.record(changeRemoved, oldRec, nil)
+		}
+		if newRec != nil && !newRec.IsDir {
+			return d.record(changeAdded, nil, newRec)
+		}
+		return nil
+	}
+
+	switch {
+	case oldRec == nil:
+		return d.record(changeAdded, nil, newRec)
+	case newRec == nil:
+		return d.record(changeRemoved, oldRec, nil)
+	case newRec.Size > oldRec.Size:
+		return d.record(changeGrown, oldRec, newRec)
+	case newRec.Size < oldRec.Size:
+		return d.record(changeShrunk, oldRec, newRec)
+	case newRec.ModTime != oldRec.ModTime,
+		oldRec.Hash != "" && newRec.Hash != "" && oldRec.Hash != newRec.Hash:
+		return d.record(changeModified, oldRec, newRec)
+	}
+	return nil
+}
+
+// record 记录一项文件变化
+func (d *differ) record(kind string, oldRec, newRec *scanner.ScanRecord) error {
+	c := DiffChange{Change: kind}
+	if oldRec != nil {
+		c.Path = oldRec.Path
+		c.OldSize = oldRec.Size
+		c.DiskDelta -= recordDisk(oldRec)
+	}
+	if newRec != nil {
+		c.Path = newRec.Path
+		c.NewSize = newRec.Size
+		c.DiskDelta += recordDisk(newRec)
+	}
+	c.SizeDelta = c.NewSize - c.OldSize
+	if !utf8.ValidString(c.Path) {
+		c.PathBytes = []byte(c.Path)
+	}
+
+	var stat *DiffStat
+	switch kind {
+	case changeAdded:
+		stat = &d.report.Added
+	case changeRemoved:
+		stat = &d.report.Removed
+	case changeGrown:
+		stat = &d.report.Grown
+	case changeShrunk:
+		stat = &d.report.Shrunk
+	default:
+		stat = &d.report.Modified
+	}
+	stat.Count++
+	stat.SizeDelta += c.SizeDelta
+	stat.DiskDelta += c.DiskDelta
+	d.report.SizeDelta += c.SizeDelta
+	d.report.DiskDelta += c.DiskDelta
+
+	d.top[kind].Push(c)
+
+	// 累加到所有上级目录
+	if c.SizeDelta != 0 || c.DiskDelta != 0 {
+		for dir := filepath.Dir(c.Path); ; dir = filepath.Dir(dir) {
+			dc := d.dirDeltas[dir]
+			if dc == nil {
+				dc = &DirChange{Path: dir}
+				d.dirDeltas[dir] = dc
+			}
+			dc.SizeDelta += c.SizeDelta
+			dc.DiskDelta += c.DiskDelta
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	if d.changes != nil {
+		data, err := json.Marshal(&c)
+		if err != nil {
+			return err
+		}
+		d.changes.Write(data)
+		d.changes.WriteByte('\n')
+	}
+	return nil
+}
+
+// trackRoot 维护所有记录的公共上级目录
+func (d *differ) trackRoot(path string) {
+	d.rootPath = scanner.WidenRoot(d.rootPath, path)
+}
+
+// finish 生成目录排行和各类变化排行
+func (d *differ) finish(topCount int) {
+	d.report.RootPath = d.rootPath
+
+	topDirs := scanner.NewTopN(topCount, func(a, b *DirChange) bool {
+		if absInt64(a.DiskDelta) != absInt64(b.DiskDelta) {
+			return absInt64(a.DiskDelta) < absInt64(b.DiskDelta)
+		}
+		return absInt64(a.SizeDelta) < absInt64(b.SizeDelta)
+	})
+	for dir, dc := range d.dirDeltas {
+		// 扫描根目录之上的目录与根目录的变化相同，不重复列出
+		if !scanner.IsPathWithin(dir, d.rootPath) {
+			continue
+		}
+		topDirs.Push(dc)
+	}
+	d.report.TopDirs = make([]DirChange, 0, topCount)
+	for _, dc := range topDirs.Sorted() {
+		d.report.TopDirs = append(d.report.TopDirs, *dc)
+	}
+
+	for kind, top := range d.top {
+		d.report.TopChanges[kind] = top.Sorted()
+	}
+}
+
+// printDiffReport 显示对比结果摘要
+func printDiffReport(r *DiffReport, topCount int) {
+	fmt.Println("════════════════════════════════════════")
+	fmt.Println("📊 扫描结果对比")
+	fmt.Println("════════════════════════════════════════")
+	fmt.Printf("旧: %s\n", r.OldFile)
+	fmt.Printf("新: %s\n", r.NewFile)
+	fmt.Printf("根目录: %s\n", r.RootPath)
+	fmt.Printf("➕ 新增: %s 个文件 (%s)\n", scanner.FormatNumber(r.Added.Count), formatSizeDelta(r.Added.SizeDelta))
+	fmt.Printf("➖ 删除: %s 个文件 (%s)\n", scanner.FormatNumber(r.Removed.Count), formatSizeDelta(r.Removed.SizeDelta))
+	fmt.Printf("📈 增大: %s 个文件 (%s)\n", scanner.FormatNumber(r.Grown.Count), formatSizeDelta(r.Grown.SizeDelta))
+	fmt.Printf("📉 减小: %s 个文件 (%s)\n", scanner.FormatNumber(r.Shrunk.Count), formatSizeDelta(r.Shrunk.SizeDelta))
+	fmt.Printf("✏️  修改: %s 个文件 (大小不变)\n", scanner.FormatNumber(r.Modified.Count))
+	fmt.Printf("📁 目录: 新增 %s | 删除 %s\n", scanner.FormatNumber(r.AddedDirs), scanner.FormatNumber(r.RemovedDirs))
+	fmt.Printf("💿 净变化: %s (磁盘占用 %s)\n", formatSizeDelta(r.SizeDelta), formatSizeDelta(r.DiskDelta))
+	fmt.Println("════════════════════════════════════════")
+
+	if len(r.TopDirs) > 0 {
+		fmt.Printf("\n变化最大的目录 (前 %d，磁盘占用 / 文件大小):\n", topCount)
+		for _, dc := range r.TopDirs {
+			fmt.Printf("  %12s / %12s  %s\n", formatSizeDelta(dc.DiskDelta), formatSizeDelta(dc.SizeDelta), dc.Path)
+		}
+	}
+
+	labels := []struct{ kind, title string }{
+		{changeAdded, "新增"}, {changeRemoved, "删除"}, {changeGrown, "增大"}, {changeShrunk, "减小"},
+	}
+	for _, l := range labels {
+		changes := r.TopChanges[l.kind]
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s幅度最大的文件:\n", l.title)
+		for _, c := range changes {
+			fmt.Printf("  %12s  %s\n", formatSizeDelta(c.SizeDelta), c.Path)
+		}
+	}
+	fmt.Println()
+}
+
+// recordDisk 记录对磁盘占用的贡献（硬链接重复的 inode 不重复计算）
+func recordDisk(rec *scanner.ScanRecord) int64 {
+	if rec.IsHardlink {
+		return 0
+	}
+	return rec.DiskUsage
+}
+
+// formatSizeDelta 格式化带符号的大小变化
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + scanner.FormatSize(-delta)
+	}
+	return "+" + scanner.FormatSize(delta)
+}
+
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -1,3 +1,11 @@
 module github.com/Zjmainstay/mac-file-search
 
-go 1.21
+go 1.22
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.31.0
+	golang.org/x/sys v0.28.0
+	golang.org/x/term v0.27.0
+)
@@ -2,9 +2,13 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +21,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -1604,12 +1609,73 @@ func (idx *Indexer) readDirWithSudo(dirPath string) ([]sudoEntry, error) {
 	return entries, nil
 }
 
+// scanOutputReader 读取mac-file-search的输出文件，压缩文件透明解压
+type scanOutputReader struct {
+	io.Reader
+	closeFn func()
+}
+
+func (r *scanOutputReader) Close() error {
+	r.closeFn()
+	return nil
+}
+
+// openScanOutput 打开mac-file-search的输出文件，gzip/zstd压缩的文件（-output 以 .gz/.zst 结尾）按内容识别并透明解压
+// 扫描被强制中断时压缩流没有正常结束，读取到最后一个刷新点后按文件结束处理
+func openScanOutput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(file, 256*1024)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &scanOutputReader{Reader: truncatedStream{zr}, closeFn: func() { file.Close() }}, nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &scanOutputReader{Reader: truncatedStream{zr}, closeFn: func() { zr.Close(); file.Close() }}, nil
+	}
+	return &scanOutputReader{Reader: br, closeFn: func() { file.Close() }}, nil
+}
+
+// truncatedStream 把压缩流意外结束的错误当作文件结束
+type truncatedStream struct {
+	r io.Reader
+}
+
+func (t truncatedStream) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// macFileScanSpec mac-file-search 的扫描配置（-spec），键名同命令行参数
+type macFileScanSpec struct {
+	Roots              []string `json:"roots"`
+	Exclude            []string `json:"exclude,omitempty"`
+	Output             string   `json:"output"`
+	Progress           string   `json:"progress"`
+	CheckpointInterval string   `json:"checkpoint_interval"`
+}
+
 // buildIndexWithMacFileScan 使用mac-file-search一次性扫描，然后解析JSON构建索引
 // 优势：只需一次sudo调用，比逐目录调用sudo ls快得多（2分钟 vs 10+分钟）
 func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File) error {
 	// 生成临时文件路径
 	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("mac-file-search-%d.json", time.Now().Unix()))
-	progressFile := filepath.Join(os.TempDir(), fmt.Sprintf("mac-file-search-progress-%d.json", time.Now().Unix()))
+	specFile := filepath.Join(os.TempDir(), fmt.Sprintf("mac-file-search-spec-%d.json", time.Now().Unix()))
 
 	defer func() {
 		// 确保删除临时文件
@@ -1618,18 +1684,13 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 		} else if !os.IsNotExist(err) {
 			logToDebugWithTime(debugLog, "[WARN] 删除临时文件失败: %s, err=%v", tmpFile, err)
 		}
-		// 删除进度文件
-		if err := os.Remove(progressFile); err == nil {
-			logToDebugWithTime(debugLog, "[CLEANUP] 进度文件已删除: %s", progressFile)
-		}
+		// 删除扫描配置
+		os.Remove(specFile)
 	}()
 
-	// 构建排除路径参数
-	var excludeArgs string
+	// 排除路径
 	idx.excludeMu.RLock()
-	if len(idx.excludePaths) > 0 {
-		excludeArgs = "-exclude " + strings.Join(idx.excludePaths, ",")
-	}
+	excludePaths := append([]string(nil), idx.excludePaths...)
 	idx.excludeMu.RUnlock()
 
 	// 获取mac-file-search可执行文件路径（按优先级查找）
@@ -1691,8 +1752,7 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 可执行文件: %s", macFileScanPath)
 		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 扫描路径: %s", rootPath)
 		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 输出文件: %s", tmpFile)
-		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 进度文件: %s", progressFile)
-		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 排除路径: %s", excludeArgs)
+		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 排除路径: %v", excludePaths)
 	}
 
 	// 构建命令
@@ -1701,86 +1761,110 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 		return fmt.Errorf("sudo密码未设置")
 	}
 
-	// 使用sudo执行mac-file-search，添加 -progress-file 参数以获取实时进度
-	cmdStr := fmt.Sprintf("echo '%s' | sudo -S '%s' -path '%s' -output '%s' -progress-file '%s' %s",
-		password, macFileScanPath, rootPath, tmpFile, progressFile, excludeArgs)
+	// 扫描参数写入扫描配置文件，通过 -spec 传给 mac-file-search，不再拼接 sh -c 命令：
+	// 路径中的逗号、引号不会被错误解析，也不会被 shell 执行；密码通过标准输入传给 sudo -S
+	// 进度事件通过标准错误实时读取（sudo 会关闭其他文件描述符），不需要临时的进度文件；APP 每次都重新扫描，不需要检查点（避免在临时目录残留检查点文件）
+	spec := macFileScanSpec{
+		Roots:              []string{rootPath},
+		Exclude:            excludePaths,
+		Output:             tmpFile,
+		Progress:           "stderr",
+		CheckpointInterval: "0s",
+	}
+	specData, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("无法生成扫描配置: %v", err)
+	}
+	// 配置中有用户的目录结构，只允许当前用户读取（sudo 以 root 运行仍可读取）
+	if err := os.WriteFile(specFile, specData, 0600); err != nil {
+		return fmt.Errorf("无法写入扫描配置: %v", err)
+	}
 
 	if debugLog != nil {
-		// 记录命令（隐藏密码）
-		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 执行命令: sudo '%s' -path '%s' -output '%s' -progress-file '%s' %s",
-			macFileScanPath, rootPath, tmpFile, progressFile, excludeArgs)
+		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 执行命令: sudo -S '%s' -spec '%s'", macFileScanPath, specFile)
+		logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 扫描配置: %s", specData)
 	}
 
 	logWithTime("调用mac-file-search扫描（预计2分钟）")
-	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd := exec.Command("sudo", "-S", "-p", "", macFileScanPath, "-spec", specFile)
+	cmd.Stdin = strings.NewReader(password + "\n")
+
+	// 进度事件流：每行一个 JSON 事件，不是 JSON 的行是 sudo 或 mac-file-search 的错误信息
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("无法读取mac-file-search的进度: %v", err)
+	}
 
 	// 启动命令
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("无法启动mac-file-search: %v", err)
 	}
 
-	// 启动goroutine监控进度文件
+	// 启动goroutine读取进度事件，读完（进程退出）后关闭 streamDone
 	scanStartTime := time.Now()
-	stopProgress := make(chan bool)
+	streamDone := make(chan struct{})
 	var totalFilesScanned int64 // 记录扫描总文件数，用于导入阶段计算进度
+	var finalDiskUsedSize int64 // 扫描阶段的diskUsedSize，用于导入阶段计算进度（70%-100%）
+	// 扫描结束状态（done 事件）
+	var scanResult struct {
+		Received bool   `json:"-"`
+		Complete bool   `json:"complete"`
+		Reason   string `json:"reason"`
+	}
 
 	go func() {
-		ticker := time.NewTicker(500 * time.Millisecond) // 每0.5秒读取一次进度
-		defer ticker.Stop()
+		defer close(streamDone)
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			var event struct {
+				Event        string  `json:"event"`
+				Phase        string  `json:"phase"`
+				Elapsed      float64 `json:"elapsed"`
+				DirCount     int64   `json:"dirCount"`
+				FileCount    int64   `json:"fileCount"`
+				TotalDisk    int64   `json:"totalDisk"`
+				DiskUsedSize int64   `json:"diskUsedSize"`
+				Percentage   float64 `json:"percentage"`
+				ErrorCount   int64   `json:"errorCount"`
+			}
+			if err := json.Unmarshal(line, &event); err != nil || event.Event == "" {
+				logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] %s", line)
+				continue
+			}
 
-		lastOffset := int64(0)
-		for {
-			select {
-			case <-stopProgress:
-				return
-			case <-ticker.C:
-				// 读取进度文件的新内容
-				if file, err := os.Open(progressFile); err == nil {
-					file.Seek(lastOffset, 0)
-					scanner := bufio.NewScanner(file)
-					for scanner.Scan() {
-						line := scanner.Text()
-						// 解析JSON进度
-						var progress struct {
-							Elapsed      float64 `json:"elapsed"`
-							DirCount     int64   `json:"dirCount"`
-							FileCount    int64   `json:"fileCount"`
-							TotalDisk    int64   `json:"totalDisk"`
-							DiskUsedSize int64   `json:"diskUsedSize"`
-							Percentage   float64 `json:"percentage"`
-							DirSpeed     float64 `json:"dirSpeed"`
-							FileSpeed    float64 `json:"fileSpeed"`
-							DiskSpeed    float64 `json:"diskSpeed"`
-							ErrorCount   int64   `json:"errorCount"`
-						}
-						if err := json.Unmarshal([]byte(line), &progress); err == nil {
-							// 扫描阶段：进度映射到0-70%
-							// 将原始进度（0-99.9%）映射到0-70%
-							mappedPercentage := progress.Percentage * 0.7
-
-							// 计算映射后的totalDisk（让前端进度条显示0-70%）
-							var mappedTotalDisk int64
-							if progress.DiskUsedSize > 0 {
-								mappedTotalDisk = int64(float64(progress.DiskUsedSize) * mappedPercentage / 100)
-							}
+			if event.Event == "done" {
+				json.Unmarshal(line, &scanResult)
+				scanResult.Received = true
+				continue
+			}
+			if event.Event != "progress" || event.Phase != "scanning" {
+				logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 阶段: %s", event.Phase)
+				continue
+			}
 
-							// 更新内部计数器
-							idx.fileCount.Store(progress.FileCount)
-							idx.dirCount.Store(progress.DirCount)
-							idx.totalDisk.Store(mappedTotalDisk)
-							totalFilesScanned = progress.FileCount + progress.DirCount
+			// 扫描阶段：进度映射到0-70%
+			// 将原始进度（0-99.9%）映射到0-70%
+			mappedPercentage := event.Percentage * 0.7
 
-							// 触发进度回调（如果有）
-							if idx.onProgress != nil {
-								// 传递映射后的totalDisk和diskUsedSize
-								idx.onProgress(progress.FileCount, progress.DirCount, mappedTotalDisk, progress.Elapsed)
-							}
-						}
-					}
-					// 记录当前读取位置
-					lastOffset, _ = file.Seek(0, 1)
-					file.Close()
-				}
+			// 计算映射后的totalDisk（让前端进度条显示0-70%）
+			var mappedTotalDisk int64
+			if event.DiskUsedSize > 0 {
+				mappedTotalDisk = int64(float64(event.DiskUsedSize) * mappedPercentage / 100)
+			}
+
+			// 更新内部计数器
+			idx.fileCount.Store(event.FileCount)
+			idx.dirCount.Store(event.DirCount)
+			idx.totalDisk.Store(mappedTotalDisk)
+			totalFilesScanned = event.FileCount + event.DirCount
+			finalDiskUsedSize = event.DiskUsedSize
+
+			// 触发进度回调（如果有）
+			if idx.onProgress != nil {
+				// 传递映射后的totalDisk和diskUsedSize
+				idx.onProgress(event.FileCount, event.DirCount, mappedTotalDisk, event.Elapsed)
 			}
 		}
 	}()
@@ -1788,6 +1872,8 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 	// 等待命令完成，同时监控停止标志
 	done := make(chan error, 1)
 	go func() {
+		// 读完进度事件后才能调用 Wait（Wait 会关闭管道）
+		<-streamDone
 		done <- cmd.Wait()
 	}()
 
@@ -1795,12 +1881,10 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
-	var err error
 	for {
 		select {
 		case err = <-done:
 			// 命令完成
-			close(stopProgress) // 停止进度监控
 			goto scanComplete
 		case <-ticker.C:
 			// 检查停止标志
@@ -1810,7 +1894,6 @@ func (idx *Indexer) buildIndexWithMacFileScan(rootPath string, debugLog *os.File
 				if cmd.Process != nil {
 					cmd.Process.Kill()
 				}
-				close(stopProgress)
 				return fmt.Errorf("用户停止索引")
 			}
 		}
@@ -1820,6 +1903,9 @@ scanComplete:
 	scanDuration := time.Since(scanStartTime).Seconds()
 
 	if err != nil {
+		if scanResult.Received && !scanResult.Complete {
+			return fmt.Errorf("mac-file-search执行失败: %s", scanResult.Reason)
+		}
 		return fmt.Errorf("mac-file-search执行失败: %v", err)
 	}
 
@@ -1833,29 +1919,6 @@ scanComplete:
 	}
 	logToDebugWithTime(debugLog, "[MAC-FILE-SEARCH] 输出文件大小: %.2f MB", float64(fileInfo.Size())/(1024*1024))
 
-	// 记录扫描阶段的diskUsedSize，用于导入阶段计算进度（70%-100%）
-	// 从进度文件获取最终的diskUsedSize
-	var finalDiskUsedSize int64
-	if totalFilesScanned > 0 {
-		// 从最后一次进度更新获取diskUsedSize
-		if file, err := os.Open(progressFile); err == nil {
-			scanner := bufio.NewScanner(file)
-			var lastLine string
-			for scanner.Scan() {
-				lastLine = scanner.Text()
-			}
-			if lastLine != "" {
-				var progress struct {
-					DiskUsedSize int64 `json:"diskUsedSize"`
-				}
-				if err := json.Unmarshal([]byte(lastLine), &progress); err == nil {
-					finalDiskUsedSize = progress.DiskUsedSize
-				}
-			}
-			file.Close()
-		}
-	}
-
 	// 解析JSON文件并导入数据库
 	logWithTime("解析JSON并导入数据库")
 	parseStart := time.Now()
@@ -1865,7 +1928,7 @@ scanComplete:
 	idx.fileCount.Store(0)
 	idx.dirCount.Store(0)
 
-	file, err := os.Open(tmpFile)
+	file, err := openScanOutput(tmpFile)
 	if err != nil {
 		return fmt.Errorf("无法打开输出文件: %v", err)
 	}
@@ -1954,18 +2017,27 @@ scanComplete:
 
 		// 解析JSON
 		var entry struct {
+			Type      string `json:"type"` // 目录汇总等附加记录带 type 字段，不导入
 			Path      string `json:"path"`
 			Name      string `json:"name"`
 			Size      int64  `json:"size"`
 			ModTime   int64  `json:"mod_time"` // 添加修改时间
 			IsDir     bool   `json:"is_dir"`
 			DiskUsage int64  `json:"disk_usage"`
+			PathBytes []byte `json:"path_bytes"` // 路径不是有效的 UTF-8 时的原始字节
 		}
 
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
 			logToDebugWithTime(debugLog, "[WARN] 解析JSON失败(行%d): %v", lineCount, err)
 			continue
 		}
+		if entry.Type != "" {
+			continue
+		}
+		if entry.PathBytes != nil {
+			entry.Path = string(entry.PathBytes)
+			entry.Name = filepath.Base(entry.Path)
+		}
 
 		// 添加到批量INSERT缓冲区
 		ext := strings.ToLower(filepath.Ext(entry.Name))
@@ -1,984 +1,541 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"runtime"
-	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
 )
 
-// FileNode 表示文件树中的一个节点
-type FileNode struct {
-	Path       string      `json:"path"`
-	Name       string      `json:"name"`
-	Size       int64       `json:"size"`       // 逻辑大小（文件声称的大小）
-	DiskUsage  int64       `json:"disk_usage"` // 实际磁盘占用（块数 * 512）
-	ModTime    int64       `json:"mod_time"`   // 修改时间（Unix timestamp）
-	IsDir      bool        `json:"is_dir"`
-	IsSparse   bool        `json:"is_sparse,omitempty"`   // 是否为稀疏文件
-	IsHardlink bool        `json:"is_hardlink,omitempty"` // 是否为硬链接（重复的inode）
-	Children   []*FileNode `json:"children,omitempty"`
-	mu         sync.RWMutex
+// splitList 解析逗号分隔的列表，去掉空白和空项
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
-// ScanOptions 扫描选项
-type ScanOptions struct {
-	RootPath         string
-	MinSize          int64
-	MaxSize          int64
-	WorkerCount      int
-	OutputFile       string   // 输出文件路径
-	ShowErrors       bool     // 是否显示错误详情
-	ExcludePaths     []string // 要排除的路径列表
-	IncludeExts      []string // 包含的文件扩展名列表 (如: .txt, .log)
-	ExcludeExts      []string // 排除的文件扩展名列表
-	NamePattern      string   // 文件名正则表达式模式
-	ProgressFile     string   // 进度信息输出文件（JSON格式，供APP调用）
-	nameRegex        *regexp.Regexp // 编译后的正则表达式（内部使用）
+// pathList 可重复指定的路径参数（如 -path a -path b）
+type pathList []string
+
+func (l *pathList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
 }
 
-// Scanner 文件扫描器
-type Scanner struct {
-	options        ScanOptions
-	root           *FileNode
-	dirQueue       chan string
-	taskWg         sync.WaitGroup // 任务计数
-	workerWg       sync.WaitGroup // worker 计数
-	nodeMap        sync.Map       // 用于快速查找父节点
-	inodeMap       sync.Map       // 跟踪已处理的 inode (key: "dev:ino")
-	dirInodeMap    sync.Map       // 跟踪已扫描的目录 inode，避免重复扫描（firmlinks等）
-	fileCount      atomic.Int64
-	dirCount       atomic.Int64
-	symlinkCount   atomic.Int64   // 符号链接计数
-	sparseCount    atomic.Int64   // 稀疏文件计数
-	hardlinkCount  atomic.Int64   // 硬链接计数（重复的 inode）
-	dupDirCount    atomic.Int64   // 重复目录计数（firmlinks等）
-	excludedCount  atomic.Int64   // 排除的目录计数
-	errorCount     atomic.Int64
-	totalSize      atomic.Int64   // 文件逻辑大小总和
-	totalDisk      atomic.Int64   // 实际磁盘占用总和（去重后）
-	diskUsedSize   int64          // 磁盘已使用空间大小
-	outputFile     *os.File       // 输出文件句柄
-	outputMu       sync.Mutex     // 输出文件锁
+func (l *pathList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-// NewScanner 创建新的扫描器
-func NewScanner(options ScanOptions) *Scanner {
-	if options.WorkerCount <= 0 {
-		options.WorkerCount = runtime.NumCPU() * 4
+// readRootsFile 读取根目录列表文件：每行一个路径，忽略空行和 # 开头的注释
+func readRootsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开根目录列表文件: %v", err)
 	}
+	defer f.Close()
 
-	// 编译正则表达式（如果提供）
-	if options.NamePattern != "" {
-		regex, err := regexp.Compile(options.NamePattern)
-		if err != nil {
-			log.Fatalf("正则表达式编译失败: %v", err)
+	var roots []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
-		options.nameRegex = regex
+		roots = append(roots, line)
 	}
-
-	return &Scanner{
-		options:  options,
-		dirQueue: make(chan string, options.WorkerCount*10),
-		root: &FileNode{
-			Path:     options.RootPath,
-			Name:     filepath.Base(options.RootPath),
-			IsDir:    true,
-			Children: make([]*FileNode, 0),
-		},
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取根目录列表文件失败: %v", err)
 	}
+	return roots, nil
 }
 
-// shouldIncludeFile 判断文件是否符合大小筛选条件
-func (s *Scanner) shouldIncludeFile(size int64) bool {
-	if s.options.MinSize > 0 && size < s.options.MinSize {
-		return false
-	}
-	if s.options.MaxSize > 0 && size > s.options.MaxSize {
-		return false
-	}
-	return true
+// parseExcludePaths 解析逗号分隔的排除路径，转换为绝对路径
+func parseExcludePaths(list string) []string {
+	return resolveExcludePaths(splitList(list))
 }
 
-// shouldExcludePath 判断路径是否应该被排除
-func (s *Scanner) shouldExcludePath(path string) bool {
-	// 检查用户指定的排除列表
-	for _, excludePath := range s.options.ExcludePaths {
-		// 精确匹配
-		if path == excludePath {
-			return true
+// resolveExcludePaths 将排除路径转换为绝对路径
+func resolveExcludePaths(paths []string) []string {
+	var excludeList []string
+	for _, p := range paths {
+		// 转换为绝对路径
+		absExclude, err := filepath.Abs(p)
+		if err != nil {
+			log.Printf("警告: 无法解析排除路径 %s: %v", p, err)
+			continue
 		}
-		// 前缀匹配：确保排除路径的子路径
-		// 例如：排除 /Volumes/Data 应该匹配 /Volumes/Data/subdir
-		// 但不应该匹配 /Volumes/DataBackup
-		if strings.HasPrefix(path, excludePath+string(filepath.Separator)) {
-			return true
+		excludeList = append(excludeList, absExclude)
+
+		// 同时获取真实路径（解析符号链接）
+		// 这样可以同时排除 /Volumes/XXX 和 /System/Volumes/Data/Volumes/XXX
+		realPath, err := filepath.EvalSymlinks(absExclude)
+		if err == nil && realPath != absExclude {
+			excludeList = append(excludeList, realPath)
+			log.Printf("排除路径: %s (实际: %s)", absExclude, realPath)
 		}
 	}
-	return false
+	return excludeList
 }
 
-// shouldIncludeFileByExt 判断文件扩展名是否符合过滤条件
-func (s *Scanner) shouldIncludeFileByExt(filename string) bool {
-	// 如果没有配置扩展名过滤，则包含所有文件
-	if len(s.options.IncludeExts) == 0 && len(s.options.ExcludeExts) == 0 {
-		return true
-	}
-
-	ext := strings.ToLower(filepath.Ext(filename))
-
-	// 如果配置了排除列表，检查是否在排除列表中
-	if len(s.options.ExcludeExts) > 0 {
-		for _, excludeExt := range s.options.ExcludeExts {
-			if ext == strings.ToLower(excludeExt) {
-				return false
-			}
+// normalizeExts 确保扩展名以 . 开头
+func normalizeExts(exts []string) []string {
+	var list []string
+	for _, ext := range exts {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
 		}
-	}
-
-	// 如果配置了包含列表，只包含列表中的扩展名
-	if len(s.options.IncludeExts) > 0 {
-		for _, includeExt := range s.options.IncludeExts {
-			if ext == strings.ToLower(includeExt) {
-				return true
-			}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
 		}
-		return false // 不在包含列表中
-	}
-
-	return true
-}
-
-// shouldIncludeFileByName 判断文件名是否符合正则表达式模式
-func (s *Scanner) shouldIncludeFileByName(filename string) bool {
-	// 如果没有配置正则表达式，则包含所有文件
-	if s.options.nameRegex == nil {
-		return true
+		list = append(list, ext)
 	}
-
-	return s.options.nameRegex.MatchString(filename)
+	return list
 }
 
-// worker 工作协程，处理目录扫描
-func (s *Scanner) worker(id int) {
-	defer s.workerWg.Done()
-
-	for dirPath := range s.dirQueue {
-		s.scanDirectory(dirPath)
-		s.taskWg.Done()
-	}
-}
-
-// scanDirectory 扫描单个目录
-func (s *Scanner) scanDirectory(dirPath string) {
-	defer func() {
-		if r := recover(); r != nil {
-			s.errorCount.Add(1)
-			if s.options.ShowErrors {
-				fmt.Fprintf(os.Stderr, "\n⚠️  panic in %s: %v\n", dirPath, r)
-			}
+// printScanInfo 显示扫描参数和磁盘使用情况
+func printScanInfo(w io.Writer, o scanner.Options) {
+	if o.OutputFile != "" {
+		name := o.OutputFile
+		if name == "-" {
+			name = "标准输出"
 		}
-	}()
-
-	// 先验证路径是否仍然存在且是目录（避免竞态条件）
-	info, err := os.Lstat(dirPath)
-	if err != nil {
-		// 文件/目录可能在扫描过程中被删除，这是正常的
-		s.errorCount.Add(1)
-		if s.options.ShowErrors {
-			fmt.Fprintf(os.Stderr, "\n⚠️  路径不存在或无法访问 %s: %v\n", dirPath, err)
-		}
-		return
-	}
-
-	// 确保是目录而不是文件（避免竞态条件导致类型变化）
-	if !info.IsDir() {
-		// 可能在加入队列后从目录变成了文件，跳过即可
-		if s.options.ShowErrors {
-			fmt.Fprintf(os.Stderr, "\n⚠️  路径不再是目录 %s\n", dirPath)
+		if o.Format != scanner.FormatJSONL {
+			name += " (" + o.Format + " 格式)"
 		}
-		return
-	}
-
-	// 检查目录是否已经扫描过（通过 dev:ino 去重，避免 firmlinks/硬链接等重复扫描）
-	// 注意：只在非根目录时进行检查，根目录总是需要扫描
-	if dirPath != s.options.RootPath {
-		stat, ok := info.Sys().(*syscall.Stat_t)
-		if ok {
-			dirInodeKey := fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
-			if _, exists := s.dirInodeMap.LoadOrStore(dirInodeKey, dirPath); exists {
-				// 这个目录已经扫描过（可能是 firmlink 或其他方式的重复访问）
-				// 静默跳过，这是正常的内部处理
-				s.dupDirCount.Add(1)
-				return
-			}
+		if o.Resume {
+			fmt.Fprintf(w, "📝 输出文件: %s (追加)\n", name)
+		} else {
+			fmt.Fprintf(w, "📝 输出文件: %s\n", name)
 		}
 	}
 
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		// 对于 bad file descriptor 等预期的系统错误，完全忽略（不计数、不显示）
-		// 这通常发生在 /dev/fd 等动态变化的虚拟目录中
-		errStr := err.Error()
-		isBadFileDescriptor := strings.Contains(errStr, "bad file descriptor")
-		isTooManyFiles := strings.Contains(errStr, "too many open files")
-
-		// 只对非预期错误计数和显示
-		if !isBadFileDescriptor {
-			s.errorCount.Add(1)
-			// "too many open files" 错误总是显示，即使没有 -errors 参数
-			if s.options.ShowErrors || isTooManyFiles {
-				fmt.Fprintf(os.Stderr, "\n⚠️  无法读取目录 %s: %v\n", dirPath, err)
-			}
-		}
-		return
+	if o.Verbose {
+		fmt.Fprintln(w, "⚠️  错误显示: 已启用")
 	}
 
-	// 获取或创建当前目录节点
-	parentNode := s.getOrCreateNode(dirPath)
-	if parentNode == nil {
-		s.errorCount.Add(1)
-		if s.options.ShowErrors {
-			fmt.Fprintf(os.Stderr, "\n⚠️  无法创建节点 %s\n", dirPath)
+	if len(o.ExcludePaths) > 0 {
+		fmt.Fprintln(w, "🚫 排除路径:")
+		for _, path := range o.ExcludePaths {
+			fmt.Fprintf(w, "   - %s\n", path)
 		}
-		return
 	}
 
-	for _, entry := range entries {
-		fullPath := filepath.Join(dirPath, entry.Name())
-
-		// 优先检查是否应该排除此路径（在获取文件信息之前，节省系统调用）
-		if s.shouldExcludePath(fullPath) {
-			s.excludedCount.Add(1)
-			continue
-		}
+	// 获取磁盘使用情况
+	total, used, free, err := scanner.DiskUsage(o.RootPath)
+	if err == nil {
+		fmt.Fprintf(w, "💿 磁盘总空间: %s\n", scanner.FormatSize(total))
+		fmt.Fprintf(w, "📊 预估已使用: %s (%.1f%%) | 剩余: %s\n",
+			scanner.FormatSize(used), float64(used)/float64(total)*100, scanner.FormatSize(free))
+	}
 
-		// 获取文件信息（不跟随符号链接）
-		info, err := os.Lstat(fullPath)
-		if err != nil {
-			// 对于 bad file descriptor 等预期的系统错误，完全忽略
-			errStr := err.Error()
-			isBadFileDescriptor := strings.Contains(errStr, "bad file descriptor")
-			isTooManyFiles := strings.Contains(errStr, "too many open files")
-
-			// 只对非预期错误计数和显示
-			if !isBadFileDescriptor {
-				s.errorCount.Add(1)
-				// "too many open files" 错误总是显示，即使没有 -errors 参数
-				if s.options.ShowErrors || isTooManyFiles {
-					fmt.Fprintf(os.Stderr, "\n⚠️  无法获取文件信息 %s: %v\n", fullPath, err)
-				}
-			}
-			continue
-		}
+	if o.OneFileSystem {
+		fmt.Fprintln(w, "💽 只扫描根目录所在的文件系统")
+	}
+	if len(o.OnlyFsTypes) > 0 {
+		fmt.Fprintf(w, "💽 只进入文件系统: %s\n", strings.Join(o.OnlyFsTypes, ", "))
+	}
+	if len(o.SkipFsTypes) > 0 {
+		fmt.Fprintf(w, "💽 跳过文件系统: %s\n", strings.Join(o.SkipFsTypes, ", "))
+	}
 
-		// 跳过符号链接，避免循环引用和重复计算
-		if info.Mode()&os.ModeSymlink != 0 {
-			s.symlinkCount.Add(1)
-			continue
+	if o.Nice || o.MaxIOPS > 0 || o.MaxEntriesPerSec > 0 {
+		line := "🐢 低负载模式:"
+		if o.MaxIOPS > 0 {
+			line += fmt.Sprintf(" 最多 %d 次 IO/秒", o.MaxIOPS)
 		}
-
-		// 跳过特殊文件（设备文件、socket等）
-		if !info.Mode().IsRegular() && !info.Mode().IsDir() {
-			continue
+		if o.MaxEntriesPerSec > 0 {
+			line += fmt.Sprintf(" 最多 %d 个目录项/秒", o.MaxEntriesPerSec)
 		}
-
-		if info.IsDir() {
-			// 创建子目录节点
-			childNode := &FileNode{
-				Path:     fullPath,
-				Name:     entry.Name(),
-				IsDir:    true,
-				Children: make([]*FileNode, 0),
-			}
-
-			// 添加到父节点
-			parentNode.mu.Lock()
-			parentNode.Children = append(parentNode.Children, childNode)
-			parentNode.mu.Unlock()
-
-			// 存储节点映射
-			s.nodeMap.Store(fullPath, childNode)
-			s.dirCount.Add(1)
-
-			// 实时写入目录信息（如果设置了文件大小筛选，则排除目录）
-			if s.options.MinSize == 0 && s.options.MaxSize == 0 {
-				s.writeFileRecord(childNode)
-			}
-
-			// 将子目录加入队列
-			s.taskWg.Add(1)
-			go func(path string) {
-				s.dirQueue <- path
-			}(fullPath)
-		} else {
-			// 处理文件
-			size := info.Size()
-
-			// 检查文件大小和扩展名过滤条件
-			if !s.shouldIncludeFile(size) {
-				continue
-			}
-
-			if !s.shouldIncludeFileByExt(entry.Name()) {
-				continue
-			}
-
-			if !s.shouldIncludeFileByName(entry.Name()) {
-				continue
-			}
-
-			// 获取实际磁盘占用
-			var diskUsage int64
-			var isSparse bool
-			var isHardlink bool
-
-			stat, ok := info.Sys().(*syscall.Stat_t)
-			if ok {
-				// Blocks 是 512 字节块的数量
-				diskUsage = stat.Blocks * 512
-
-				// 如果实际占用小于逻辑大小的 95%，认为是稀疏文件
-				if size > 0 && float64(diskUsage) < float64(size)*0.95 {
-					isSparse = true
-					s.sparseCount.Add(1)
-				}
-
-				// 检查是否为硬链接（通过 dev:ino 去重）
-				// 只对硬链接数 > 1 的文件进行去重检查
-				if stat.Nlink > 1 {
-					inodeKey := fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
-					if _, exists := s.inodeMap.LoadOrStore(inodeKey, true); exists {
-						// 这是一个硬链接，已经计算过磁盘占用
-						isHardlink = true
-						s.hardlinkCount.Add(1)
-					}
-				}
-			} else {
-				// 无法获取块信息，使用逻辑大小
-				diskUsage = size
-			}
-
-			fileNode := &FileNode{
-				Path:       fullPath,
-				Name:       entry.Name(),
-				Size:       size,
-				DiskUsage:  diskUsage,
-				ModTime:    info.ModTime().Unix(), // 添加修改时间
-				IsSparse:   isSparse,
-				IsHardlink: isHardlink,
-				IsDir:      false,
-			}
-
-			parentNode.mu.Lock()
-			parentNode.Children = append(parentNode.Children, fileNode)
-			parentNode.mu.Unlock()
-
-			s.fileCount.Add(1)
-			s.totalSize.Add(size)
-
-			// 只在首次遇到 inode 时累加磁盘占用
-			if !isHardlink {
-				s.totalDisk.Add(diskUsage)
-			}
-
-			// 实时写入文件信息
-			s.writeFileRecord(fileNode)
+		if o.Nice {
+			line += " 降低进程优先级，系统负载高时自动减少 worker"
 		}
+		fmt.Fprintln(w, line)
 	}
-}
 
-// writeFileRecord 实时写入文件记录
-func (s *Scanner) writeFileRecord(node *FileNode) {
-	if s.outputFile == nil {
-		return
+	if len(o.RootPaths) > 1 {
+		fmt.Fprintf(w, "开始扫描 %d 个根目录:\n", len(o.RootPaths))
+		for _, path := range o.RootPaths {
+			fmt.Fprintf(w, "   - %s\n", path)
+		}
+	} else {
+		fmt.Fprintf(w, "开始扫描: %s\n", o.RootPath)
 	}
-
-	s.outputMu.Lock()
-	defer s.outputMu.Unlock()
-
-	// 写入JSON Lines格式，每行一个文件记录
-	var line string
-	if node.IsHardlink {
-		line = fmt.Sprintf("{\"path\":%q,\"name\":%q,\"size\":%d,\"disk_usage\":%d,\"mod_time\":%d,\"is_dir\":%t,\"is_hardlink\":true}\n",
-			node.Path, node.Name, node.Size, node.DiskUsage, node.ModTime, node.IsDir)
-	} else if node.IsSparse {
-		line = fmt.Sprintf("{\"path\":%q,\"name\":%q,\"size\":%d,\"disk_usage\":%d,\"mod_time\":%d,\"is_dir\":%t,\"is_sparse\":true}\n",
-			node.Path, node.Name, node.Size, node.DiskUsage, node.ModTime, node.IsDir)
+	if o.MaxOpenDirs > 0 {
+		fmt.Fprintf(w, "工作协程数: %d (遍历顺序: %s, 同时打开的目录 ≤ %d)\n", o.WorkerCount, o.Order, o.MaxOpenDirs)
 	} else {
-		line = fmt.Sprintf("{\"path\":%q,\"name\":%q,\"size\":%d,\"disk_usage\":%d,\"mod_time\":%d,\"is_dir\":%t}\n",
-			node.Path, node.Name, node.Size, node.DiskUsage, node.ModTime, node.IsDir)
+		fmt.Fprintf(w, "工作协程数: %d (遍历顺序: %s)\n", o.WorkerCount, o.Order)
 	}
-	_, err := s.outputFile.WriteString(line)
-	if err != nil && s.options.ShowErrors {
-		fmt.Fprintf(os.Stderr, "\n⚠️  写入文件失败: %v\n", err)
+	if o.MaxDepth > 0 {
+		fmt.Fprintf(w, "最大深度: %d\n", o.MaxDepth)
 	}
-}
-
-// getOrCreateNode 获取或创建节点
-func (s *Scanner) getOrCreateNode(path string) *FileNode {
-	if path == s.options.RootPath {
-		return s.root
+	if o.MinSize > 0 {
+		fmt.Fprintf(w, "最小文件大小: %s\n", scanner.FormatSize(o.MinSize))
 	}
-
-	if node, ok := s.nodeMap.Load(path); ok {
-		return node.(*FileNode)
+	if o.MaxSize > 0 {
+		fmt.Fprintf(w, "最大文件大小: %s\n", scanner.FormatSize(o.MaxSize))
 	}
-
-	return nil
-}
-
-// Scan 开始扫描
-func (s *Scanner) Scan() error {
-	// 打开输出文件
-	if s.options.OutputFile != "" {
-		f, err := os.Create(s.options.OutputFile)
-		if err != nil {
-			return fmt.Errorf("无法创建输出文件: %v", err)
-		}
-		s.outputFile = f
-		defer f.Close()
-
-		// 写入文件头
-		fmt.Fprintf(f, "# 文件扫描结果 - JSON Lines 格式\n")
-		fmt.Fprintf(f, "# 扫描路径: %s\n", s.options.RootPath)
-		fmt.Fprintf(f, "# 开始时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-		fmt.Fprintf(f, "# 每行一个JSON对象: {\"path\":\"...\",\"name\":\"...\",\"size\":123,\"is_dir\":false}\n")
-		fmt.Fprintln(f)
-
-		fmt.Printf("📝 输出文件: %s\n", s.options.OutputFile)
+	if len(o.Users) > 0 {
+		fmt.Fprintf(w, "用户: %s\n", strings.Join(o.Users, ", "))
 	}
-
-	if s.options.ShowErrors {
-		fmt.Println("⚠️  错误显示: 已启用")
+	if len(o.Groups) > 0 {
+		fmt.Fprintf(w, "用户组: %s\n", strings.Join(o.Groups, ", "))
 	}
-
-	if len(s.options.ExcludePaths) > 0 {
-		fmt.Println("🚫 排除路径:")
-		for _, path := range s.options.ExcludePaths {
-			fmt.Printf("   - %s\n", path)
-		}
+	if o.Perm != "" {
+		fmt.Fprintf(w, "权限: %s\n", o.Perm)
 	}
-
-	// 获取磁盘使用情况
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs(s.options.RootPath, &stat); err == nil {
-		// 总空间 = 总块数 * 块大小
-		totalSize := int64(stat.Blocks) * int64(stat.Bsize)
-		// 已使用空间 = (总块数 - 空闲块数) * 块大小
-		s.diskUsedSize = (int64(stat.Blocks) - int64(stat.Bfree)) * int64(stat.Bsize)
-		freeSize := int64(stat.Bfree) * int64(stat.Bsize)
-
-		usagePercent := float64(s.diskUsedSize) / float64(totalSize) * 100
-
-		fmt.Printf("💿 磁盘总空间: %s\n", formatSize(totalSize))
-		fmt.Printf("📊 预估已使用: %s (%.1f%%) | 剩余: %s\n",
-			formatSize(s.diskUsedSize), usagePercent, formatSize(freeSize))
-	}
-
-	fmt.Printf("开始扫描: %s\n", s.options.RootPath)
-	fmt.Printf("工作协程数: %d\n", s.options.WorkerCount)
-	if s.options.MinSize > 0 {
-		fmt.Printf("最小文件大小: %s\n", formatSize(s.options.MinSize))
+	if o.Newer != "" {
+		fmt.Fprintf(w, "晚于: %s\n", o.Newer)
 	}
-	if s.options.MaxSize > 0 {
-		fmt.Printf("最大文件大小: %s\n", formatSize(s.options.MaxSize))
+	if o.Older != "" {
+		fmt.Fprintf(w, "早于: %s\n", o.Older)
 	}
-	if s.diskUsedSize > 0 {
-		fmt.Printf("\n💡 将根据已使用空间显示扫描进度\n")
+	if err == nil && used > 0 {
+		fmt.Fprintf(w, "\n💡 将根据已使用空间显示扫描进度\n")
 	} else {
-		fmt.Printf("\n💡 提示: 无法获取磁盘使用信息，将显示实时扫描速度和统计信息\n")
+		fmt.Fprintf(w, "\n💡 提示: 无法获取磁盘使用信息，将显示实时扫描速度和统计信息\n")
 	}
-	fmt.Print("\n")
-
-	startTime := time.Now()
-
-	// 存储根节点
-	s.nodeMap.Store(s.options.RootPath, s.root)
-
-	// 启动工作协程
-	for i := 0; i < s.options.WorkerCount; i++ {
-		s.workerWg.Add(1)
-		go s.worker(i)
-	}
-
-	// 启动进度显示
-	done := make(chan bool)
-	go s.showProgress(done)
-
-	// 添加根目录到队列
-	s.taskWg.Add(1)
-	s.dirQueue <- s.options.RootPath
-
-	// 等待所有任务完成
-	s.taskWg.Wait()
-	close(s.dirQueue)
-
-	// 等待所有 worker 退出
-	s.workerWg.Wait()
-	close(done)
-
-	// 清除进度显示
-	if s.diskUsedSize > 0 {
-		// 清除进度条和统计行，然后显示100%完成
-		fmt.Print("\r\033[K\033[1B\r\033[K")
+	fmt.Fprint(w, "\n")
+}
 
-		// 显示100%完成进度条
-		progressBar := "["
-		for i := 0; i < 40; i++ {
-			progressBar += "█"
-		}
-		progressBar += "] 100.0%"
-		fmt.Println(progressBar)
+// printStats 打印扫描统计信息，interrupted 不为 nil 时表示扫描被中断，统计只包含已扫描的部分
+func printStats(w io.Writer, o scanner.Options, st *scanner.Stats, interrupted error) {
+	fmt.Fprint(w, "\n")
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	if interrupted != nil {
+		fmt.Fprintf(w, "⏹️  扫描已中断: %v\n", interrupted)
 	} else {
-		fmt.Print("\r\033[K")
+		fmt.Fprintln(w, "✅ 扫描完成!")
 	}
-
-	fmt.Println("所有扫描任务已完成")
-
-	duration := time.Since(startTime)
-
-	// 打印统计信息
-	fmt.Print("\n")
-	fmt.Println("════════════════════════════════════════")
-	fmt.Println("✅ 扫描完成!")
-	fmt.Println("════════════════════════════════════════")
-	fmt.Printf("⏱️  用时: %v\n", duration)
-	fmt.Printf("📁 目录数: %s\n", formatNumber(s.dirCount.Load()))
-	fmt.Printf("📄 文件数: %s\n", formatNumber(s.fileCount.Load()))
-	fmt.Printf("💿 磁盘占用: %s\n", formatSize(s.totalDisk.Load()))
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	fmt.Fprintf(w, "⏱️  用时: %v\n", st.Duration)
+	fmt.Fprintf(w, "📁 目录数: %s\n", scanner.FormatNumber(st.Dirs))
+	fmt.Fprintf(w, "📄 文件数: %s\n", scanner.FormatNumber(st.Files))
+	fmt.Fprintf(w, "💿 磁盘占用: %s\n", scanner.FormatSize(st.TotalDisk))
 
 	// 计算平均速度
-	seconds := duration.Seconds()
+	seconds := st.Duration.Seconds()
 	if seconds > 0 {
-		fmt.Printf("⚡ 平均速度: %s 个文件/秒, %s/秒\n",
-			formatNumber(int64(float64(s.fileCount.Load())/seconds)),
-			formatSpeed(float64(s.totalDisk.Load())/seconds))
+		fmt.Fprintf(w, "⚡ 平均速度: %s 个文件/秒, %s/秒\n",
+			scanner.FormatNumber(int64(float64(st.Files)/seconds)),
+			scanner.FormatSpeed(float64(st.TotalDisk)/seconds))
 	}
 
-	if s.symlinkCount.Load() > 0 {
-		fmt.Printf("🔗 符号链接: %s (已跳过)\n", formatNumber(s.symlinkCount.Load()))
+	if st.Symlinks > 0 {
+		if o.FollowSymlinks {
+			fmt.Fprintf(w, "🔗 符号链接: %s (已跟随，断开 %d，指向扫描目录之外 %d)\n",
+				scanner.FormatNumber(st.Symlinks), st.BrokenLinks, st.EscapingLinks)
+		} else {
+			fmt.Fprintf(w, "🔗 符号链接: %s (已跳过)\n", scanner.FormatNumber(st.Symlinks))
+		}
 	}
 
-	if s.hardlinkCount.Load() > 0 {
-		fmt.Printf("🔗 硬链接: %s (已去重)\n", formatNumber(s.hardlinkCount.Load()))
+	if st.Hardlinks > 0 {
+		if o.FollowSymlinks {
+			fmt.Fprintf(w, "🔗 重复的文件: %s (硬链接或符号链接指向同一文件，已去重)\n", scanner.FormatNumber(st.Hardlinks))
+		} else {
+			fmt.Fprintf(w, "🔗 硬链接: %s (已去重)\n", scanner.FormatNumber(st.Hardlinks))
+		}
 	}
 
-	if s.excludedCount.Load() > 0 {
-		fmt.Printf("🚫 已排除: %s 个目录/文件\n", formatNumber(s.excludedCount.Load()))
+	if st.HashErrors > 0 {
+		fmt.Fprintf(w, "🔐 哈希失败: %s 个文件\n", scanner.FormatNumber(st.HashErrors))
 	}
 
-	if s.errorCount.Load() > 0 {
-		fmt.Printf("⚠️  错误数: %d\n", s.errorCount.Load())
+	if st.ReusedDirs > 0 {
+		fmt.Fprintf(w, "♻️  未变化目录: %s (沿用上次结果)\n", scanner.FormatNumber(st.ReusedDirs))
 	}
-	fmt.Println("════════════════════════════════════════")
-
-	return nil
-}
-
-// showProgress 显示扫描进度
-func (s *Scanner) showProgress(done chan bool) {
-	ticker := time.NewTicker(500 * time.Millisecond) // 每0.5秒更新一次，更流畅
-	defer ticker.Stop()
 
-	startTime := time.Now()
-	lastDirs := int64(0)
-	lastFiles := int64(0)
-	lastDisk := int64(0)
-
-	for {
-		select {
-		case <-done:
-			return
-		case <-ticker.C:
-			elapsed := time.Since(startTime).Seconds()
-			currentDirs := s.dirCount.Load()
-			currentFiles := s.fileCount.Load()
-			currentDisk := s.totalDisk.Load()
-			errors := s.errorCount.Load()
-
-			// 计算速度
-			dirSpeed := float64(currentDirs-lastDirs) / 0.5
-			fileSpeed := float64(currentFiles-lastFiles) / 0.5
-			diskSpeed := float64(currentDisk-lastDisk) / 0.5
-
-			lastDirs = currentDirs
-			lastFiles = currentFiles
-			lastDisk = currentDisk
-
-			// 如果启用了进度文件输出，写入JSON格式的进度信息
-			if s.options.ProgressFile != "" {
-				percentage := 0.0
-				if s.diskUsedSize > 0 && currentDisk > 0 {
-					percentage = float64(currentDisk) / float64(s.diskUsedSize) * 100
-					if percentage > 99.9 {
-						percentage = 99.9
-					}
-				}
-				progressJSON := fmt.Sprintf(`{"elapsed":%.1f,"dirCount":%d,"fileCount":%d,"totalDisk":%d,"diskUsedSize":%d,"percentage":%.1f,"dirSpeed":%.0f,"fileSpeed":%.0f,"diskSpeed":%.0f,"errorCount":%d}`,
-					elapsed, currentDirs, currentFiles, currentDisk, s.diskUsedSize, percentage, dirSpeed*2, fileSpeed*2, diskSpeed*2, errors)
-
-				// 追加写入进度文件
-				if f, err := os.OpenFile(s.options.ProgressFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
-					f.WriteString(progressJSON + "\n")
-					f.Close()
-				}
-				continue // 不显示文本进度条
-			}
+	if st.Excluded > 0 {
+		fmt.Fprintf(w, "🚫 已排除: %s 个目录/文件\n", scanner.FormatNumber(st.Excluded))
+	}
 
-			// 构建进度条
-			var progressBar string
-			if s.diskUsedSize > 0 && currentDisk > 0 {
-				// 计算进度百分比（基于已使用空间）
-				percentage := float64(currentDisk) / float64(s.diskUsedSize) * 100
-				// 扫描过程中最多显示99.9%，只有完成时才显示100%
-				if percentage > 99.9 {
-					percentage = 99.9
-				}
-
-				// 生成进度条（40个字符宽）
-				barWidth := 40
-				filledWidth := int(percentage / 100 * float64(barWidth))
-				if filledWidth > barWidth {
-					filledWidth = barWidth
-				}
-
-				progressBar = "["
-				for i := 0; i < barWidth; i++ {
-					if i < filledWidth {
-						progressBar += "█"
-					} else {
-						progressBar += "░"
-					}
-				}
-				progressBar += fmt.Sprintf("] %.1f%%", percentage)
-			}
+	if st.SkippedMounts > 0 {
+		fmt.Fprintf(w, "💽 跳过挂载点: %s\n", scanner.FormatNumber(st.SkippedMounts))
+	}
 
-			// 清除当前行并显示进度
-			if progressBar != "" {
-				// 显示进度条版本
-				fmt.Printf("\r\033[K%s\n\r\033[K⏱️  %.0fs | 📁 %s (%s/s) | 📄 %s (%s/s) | 💿 %s (%s/s)",
-					progressBar,
-					elapsed,
-					formatNumber(currentDirs),
-					formatNumber(int64(dirSpeed*2)),
-					formatNumber(currentFiles),
-					formatNumber(int64(fileSpeed*2)),
-					formatSize(currentDisk),
-					formatSpeed(diskSpeed*2))
-				// 上移一行以覆盖进度条
-				fmt.Print("\033[1A")
-			} else {
-				// 没有磁盘总空间信息，显示原有格式
-				fmt.Printf("\r\033[K⏱️  %.0fs | 📁 %s (%s/s) | 📄 %s (%s/s) | 💿 %s (%s/s)",
-					elapsed,
-					formatNumber(currentDirs),
-					formatNumber(int64(dirSpeed*2)),
-					formatNumber(currentFiles),
-					formatNumber(int64(fileSpeed*2)),
-					formatSize(currentDisk),
-					formatSpeed(diskSpeed*2))
-			}
+	if st.DepthLimited > 0 {
+		fmt.Fprintf(w, "📏 达到最大深度未进入: %s 个目录\n", scanner.FormatNumber(st.DepthLimited))
+	}
 
-			if errors > 0 {
-				fmt.Printf(" | ⚠️  %d", errors)
-			}
-		}
+	if st.Errors > 0 {
+		fmt.Fprintf(w, "⚠️  错误数: %d\n", st.Errors)
+	}
+	if st.MinWorkers > 0 && st.MinWorkers < o.WorkerCount {
+		fmt.Fprintf(w, "🐢 系统负载较高时 worker 数最少减至 %d\n", st.MinWorkers)
 	}
 }
 
-// GetFileTree 获取文件树
-func (s *Scanner) GetFileTree() *FileNode {
-	return s.root
+// signalError 收到信号导致扫描中断，作为 ctx 的取消原因写入结束记录
+type signalError struct {
+	sig syscall.Signal
 }
 
-// PrintTree 打印文件树（限制深度避免输出过多）
-func (s *Scanner) PrintTree(maxDepth int) {
-	if maxDepth > 0 {
-		fmt.Printf("\n文件树结构 (显示深度: %d 层):\n", maxDepth)
-	} else {
-		fmt.Println("\n文件树结构 (完整):")
-	}
-	printNode(s.root, "", 0, maxDepth)
+func (e *signalError) Error() string {
+	return fmt.Sprintf("收到 %v 信号", e.sig)
 }
 
-// printNode 递归打印节点
-func printNode(node *FileNode, prefix string, depth, maxDepth int) {
-	// maxDepth <= 0 表示不限制深度
-	if maxDepth > 0 && depth > maxDepth {
-		return
-	}
-
-	node.mu.RLock()
-	defer node.mu.RUnlock()
-
-	icon := "📄"
-	if node.IsDir {
-		icon = "📁"
-	}
-
-	sizeStr := ""
-	if !node.IsDir {
-		if node.IsSparse && node.DiskUsage < node.Size {
-			// 稀疏文件显示两个大小
-			sizeStr = fmt.Sprintf(" (💿 %s / 💾 %s)", formatSize(node.DiskUsage), formatSize(node.Size))
-		} else {
-			sizeStr = fmt.Sprintf(" (%s)", formatSize(node.Size))
+func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "browse":
+			runBrowse(os.Args[2:])
+			return
+		case "analyze":
+			runAnalyze(os.Args[2:])
+			return
 		}
 	}
 
-	fmt.Printf("%s%s %s%s\n", prefix, icon, node.Name, sizeStr)
+	// 命令行参数
+	sp := &scanSpec{}
+	flag.Var(&sp.Roots, "path", "扫描的根目录路径，可重复指定多个（共享 worker、硬链接去重和输出文件，相互包含的根目录只扫描一次），默认为当前目录")
+	flag.StringVar(&sp.RootsFile, "roots-file", "", "从文件读取扫描的根目录（每行一个，# 开头为注释），与 -path 合并")
+	specFile := flag.String("spec", "", "从 JSON 扫描配置读取全部参数（根目录、排除、筛选、输出和进度文件等，键名同命令行参数），- 表示从标准输入读取；不能再指定其他参数")
+	flag.StringVar((*string)(&sp.MinSize), "min", "0", "最小文件大小 (支持: 100M, 1.5G, 1024 等)")
+	flag.StringVar((*string)(&sp.MaxSize), "max", "0", "最大文件大小 (支持: 100M, 1.5G, 1024 等), 0表示不限制")
+	flag.IntVar(&sp.Workers, "workers", runtime.NumCPU()*4, "并发工作协程数")
+	flag.StringVar(&sp.Order, "order", scanner.OrderDFS, "目录遍历顺序: dfs（深度优先，待扫描目录占用内存少）或 bfs（广度优先，先扫描较浅的目录）")
+	flag.IntVar(&sp.MaxOpenDirs, "max-open-dirs", 0, "同时打开的目录句柄上限（遇到 too many open files 时调低），0表示不额外限制（每个 worker 最多打开一个）")
+	flag.BoolVar(&sp.ShowTree, "tree", false, "显示文件树结构")
+	flag.IntVar(&sp.TreeDepth, "depth", 0, "文件树显示深度，0表示不限制（默认不限制）")
+	flag.StringVar(&sp.OutputFile, "output", "", "输出文件路径（默认 JSON Lines格式），实时写入防止数据丢失；以 .gz 或 .zst 结尾时压缩输出，- 表示标准输出")
+	flag.StringVar(&sp.Format, "format", scanner.FormatJSONL, "输出格式: jsonl, csv, tsv, null（NUL 分隔的路径，配合 xargs -0）, template（配合 -printf）；非 jsonl 格式未指定 -output 时输出到标准输出")
+	flag.StringVar(&sp.Template, "printf", "", "按模板输出每条记录（隐含 -format template），如 '{size}\\t{path}\\n'，字段: path name dir ext root size disk_usage mtime is_dir type hash link_target 等")
+	flag.BoolVar(&sp.ShowErrors, "errors", false, "显示错误详情")
+	flag.StringVar(&sp.ErrorsFile, "errors-file", "", "将每个错误写入 JSON Lines 文件（errno 分类、路径、所在目录），用于找出需要额外权限的目录")
+	flag.Var(&sp.Exclude, "exclude", "要排除的路径，多个路径用逗号分隔（例如: /Volumes/ExtDisk,/private/tmp）；路径中有逗号时使用 -spec")
+	flag.Var(&sp.ExcludeGlobs, "exclude-glob", "要排除的 glob 模式（gitignore 语法，相对扫描根目录），多个用逗号分隔（例如: **/node_modules,*.photoslibrary,**/.git/objects）")
+	flag.BoolVar(&sp.OneFileSystem, "xdev", false, "只扫描根目录所在的文件系统，不进入其他挂载点（外接磁盘、网络共享等）")
+	flag.IntVar(&sp.MaxDepth, "max-depth", 0, "最大扫描深度（根目录的子项为第 1 层），更深的目录只记录自身不进入，0表示不限制")
+	flag.Var(&sp.OnlyFsTypes, "fstype", "只进入这些文件系统类型的挂载点，多个用逗号分隔（例如: apfs,hfs）")
+	flag.Var(&sp.SkipFsTypes, "skip-fstype", "跳过这些文件系统类型的挂载点，多个用逗号分隔（例如: nfs,smbfs,fuse,proc,sysfs）")
+	flag.BoolVar(&sp.FollowSymlinks, "follow-symlinks", false, "跟随符号链接扫描链接目标（按 dev:ino 去重，指回上级目录的循环链接只扫描一次）")
+	flag.StringVar(&sp.SymlinkReport, "symlink-report", "", "断开的符号链接和指向扫描目录之外的符号链接的 JSON 报告输出路径（需要 -follow-symlinks）")
+	flag.BoolVar(&sp.IgnoreFiles, "ignore-files", false, "遵循扫描中遇到的 .gitignore/.ignore/.mfsignore（作用于所在目录及其子目录，支持 ! 重新包含）")
+	flag.Var(&sp.IncludeExts, "include-ext", "只包含的文件扩展名，多个用逗号分隔（例如: .txt,.log,.md）")
+	flag.Var(&sp.ExcludeExts, "exclude-ext", "要排除的文件扩展名，多个用逗号分隔（例如: .tmp,.cache）")
+	flag.StringVar(&sp.NamePattern, "name", "", "文件名正则表达式过滤（例如: ^test.*\\.go$）")
+	flag.BoolVar(&sp.Meta, "meta", false, "记录属主(uid/gid及名称)、权限、inode、链接数、访问/变化/创建时间，并在统计中按用户汇总")
+	flag.Var(&sp.Users, "user", "只包含这些用户的文件，多个用逗号分隔（用户名或 uid）")
+	flag.Var(&sp.Groups, "group", "只包含这些用户组的文件，多个用逗号分隔（组名或 gid）")
+	flag.StringVar(&sp.Perm, "perm", "", "权限条件（find 语法）: 644 完全相同, -0002 包含全部位, /111 包含任意一位")
+	flag.StringVar(&sp.Newer, "newer", "", "只包含时间晚于此的文件: [mtime|atime|ctime|btime:]30d/12h/2w 或 2006-01-02（默认 mtime）")
+	flag.StringVar(&sp.Older, "older", "", "只包含时间早于此的文件，格式同 -newer（例如: atime:180d）")
+	flag.StringVar(&sp.Progress, "progress", "", "以 NDJSON 输出进度事件（阶段、当前目录、队列深度、剩余时间、错误数，最后一行为 done 事件）: stderr, fd:N, unix:/path/to.sock 或文件路径，设置后不显示文本进度条")
+	flag.StringVar(&sp.ProgressFile, "progress-file", "", "将进度事件写入指定文件，等同于 -progress 文件路径")
+	flag.StringVar(&sp.SinceOutput, "since-output", "", "增量扫描：上一次的输出文件，只重新读取修改时间变化的目录")
+	flag.BoolVar(&sp.Resume, "resume", false, "从 -output 对应的检查点恢复中断的扫描，继续追加到同一输出文件")
+	flag.BoolVar(&sp.FindDupes, "dupes", false, "扫描完成后查找内容重复的文件（按大小分组，再用部分哈希和完整 SHA-256 确认）")
+	flag.StringVar(&sp.DupesOutput, "dupes-output", "", "重复文件 JSON 报告的输出路径，默认输出到标准输出")
+	flag.StringVar(&sp.HashAlgo, "hash", "", "为每个文件计算内容哈希: sha256, xxhash, blake（BLAKE2b-256）")
+	flag.StringVar((*string)(&sp.HashMax), "hash-max", "1G", "计算哈希的文件大小上限 (支持: 100M, 1.5G 等), 0表示不限制")
+	flag.StringVar(&sp.HashLarge, "hash-large", scanner.HashLargeSkip, "超过 -hash-max 的文件: skip 不计算, sample 抽样计算（头/中/尾各1MB）")
+	flag.IntVar(&sp.HashWorkers, "hash-workers", 4, "计算哈希的并发数（独立于扫描协程）")
+	flag.BoolVar(&sp.DirSummary, "dir-summary", true, "扫描完成后为每个目录写入汇总记录（type 为 dir_summary，包含递归的大小、磁盘占用、文件数和子目录数）")
+	flag.IntVar(&sp.TopCount, "top", 0, "扫描完成后显示占用空间最大的 N 个文件、N 个目录和最旧的 N 个大文件，0 表示不显示")
+	flag.StringVar(&sp.TopOutput, "top-output", "", "排行的 JSON 报告输出路径（需要 -top）")
+	flag.StringVar((*string)(&sp.TopOldMin), "top-old-min", "100M", "\"最旧的大文件\" 的大小下限 (支持: 100M, 1.5G 等)")
+	flag.BoolVar(&sp.SortedOutput, "sorted", false, "扫描完成后将输出文件按路径排序（确定的输出顺序，diff 可直接流式读取）")
+	flag.BoolVar(&sp.Nice, "nice", false, "低负载模式：降低进程 CPU/IO 优先级，系统负载高时自动减少 worker（适合在工作时间定时扫描）")
+	flag.IntVar(&sp.MaxIOPS, "max-iops", 0, "ReadDir/Lstat 调用的速率上限（次/秒，所有 worker 共享），0表示不限制")
+	flag.IntVar(&sp.MaxEntries, "max-entries-per-sec", 0, "处理目录项的速率上限（个/秒，所有 worker 共享），0表示不限制")
+	flag.DurationVar((*time.Duration)(&sp.CheckpointInterval), "checkpoint-interval", 30*time.Second, "检查点保存间隔（需要 -output），0 表示不保存")
 
-	if node.IsDir && len(node.Children) > 0 {
-		childCount := len(node.Children)
+	flag.Parse()
 
-		for i := 0; i < childCount; i++ {
-			child := node.Children[i]
-			isLast := i == childCount-1
-			var newPrefix string
-			if isLast {
-				newPrefix = prefix + "└── "
-			} else {
-				newPrefix = prefix + "├── "
+	// 扫描配置代替全部命令行参数：未写入配置的键使用上面的默认值
+	if *specFile != "" {
+		var others []string
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "spec" {
+				others = append(others, "-"+f.Name)
 			}
-			printNode(child, newPrefix, depth+1, maxDepth)
+		})
+		others = append(others, flag.Args()...)
+		if len(others) > 0 {
+			log.Fatalf("使用 -spec 时不能再指定其他参数（%s），请写入扫描配置", strings.Join(others, " "))
+		}
+		if err := loadSpec(*specFile, sp); err != nil {
+			log.Fatalf("%v", err)
 		}
 	}
-}
 
-// formatSize 格式化文件大小
-func formatSize(size int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-		TB = GB * 1024
-	)
-
-	switch {
-	case size >= TB:
-		return fmt.Sprintf("%.2f TB", float64(size)/TB)
-	case size >= GB:
-		return fmt.Sprintf("%.2f GB", float64(size)/GB)
-	case size >= MB:
-		return fmt.Sprintf("%.2f MB", float64(size)/MB)
-	case size >= KB:
-		return fmt.Sprintf("%.2f KB", float64(size)/KB)
-	default:
-		return fmt.Sprintf("%d B", size)
+	// -printf 隐含 template 格式；非 JSON Lines 格式默认输出到标准输出，便于接入管道
+	if sp.Template != "" && sp.Format == scanner.FormatJSONL {
+		sp.Format = scanner.FormatTemplate
 	}
-}
-
-// formatSpeed 格式化速度
-func formatSpeed(bytesPerSec float64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-	)
-
-	switch {
-	case bytesPerSec >= GB:
-		return fmt.Sprintf("%.1f GB", bytesPerSec/GB)
-	case bytesPerSec >= MB:
-		return fmt.Sprintf("%.1f MB", bytesPerSec/MB)
-	case bytesPerSec >= KB:
-		return fmt.Sprintf("%.1f KB", bytesPerSec/KB)
-	default:
-		return fmt.Sprintf("%.0f B", bytesPerSec)
+	if sp.Format != scanner.FormatJSONL && sp.OutputFile == "" {
+		sp.OutputFile = "-"
+	}
+	// 记录输出到标准输出时，进度和统计信息改为输出到标准错误
+	console := io.Writer(os.Stdout)
+	if sp.OutputFile == "-" {
+		console = os.Stderr
 	}
-}
 
-// formatNumber 格式化数字（添加千位分隔符）
-func formatNumber(n int64) string {
-	if n < 1000 {
-		return fmt.Sprintf("%d", n)
+	// 进度事件流，-progress-file 等同于 -progress 指定文件
+	progressTarget := sp.Progress
+	if sp.ProgressFile != "" {
+		if progressTarget != "" {
+			log.Fatalf("-progress 和 -progress-file 只能指定一个")
+		}
+		progressTarget = sp.ProgressFile
 	}
-	if n < 1000000 {
-		return fmt.Sprintf("%d,%03d", n/1000, n%1000)
+	if progressTarget == "stderr" && console == os.Stderr {
+		log.Fatalf("记录输出到标准输出时统计信息使用标准错误，-progress 请改用 fd:N、unix:PATH 或文件路径")
 	}
-	return fmt.Sprintf("%d,%03d,%03d", n/1000000, (n/1000)%1000, n%1000)
-}
 
-// parseSize 解析人性化的文件大小字符串 (支持 K/M/G/T 后缀)
-// 例如: "100M" -> 104857600, "1.5G" -> 1610612736
-func parseSize(sizeStr string) (int64, error) {
-	if sizeStr == "" || sizeStr == "0" {
-		return 0, nil
+	// 创建扫描器
+	sink := &consoleSink{
+		out:        console,
+		showErrors: sp.ShowErrors,
 	}
-
-	// 去除空格
-	sizeStr = strings.TrimSpace(sizeStr)
-
-	// 转换为大写以支持大小写
-	upper := strings.ToUpper(sizeStr)
-
-	// 定义单位
-	multipliers := map[string]int64{
-		"K": 1024,
-		"M": 1024 * 1024,
-		"G": 1024 * 1024 * 1024,
-		"T": 1024 * 1024 * 1024 * 1024,
+	if progressTarget != "" {
+		ps, err := openProgressStream(progressTarget)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		sink.progress = ps
 	}
-
-	// 检查是否有单位后缀
-	for suffix, multiplier := range multipliers {
-		if strings.HasSuffix(upper, suffix) {
-			// 去除后缀，解析数字
-			numStr := strings.TrimSuffix(upper, suffix)
-			numStr = strings.TrimSpace(numStr)
-
-			// 解析数字（支持小数）
-			num, err := strconv.ParseFloat(numStr, 64)
-			if err != nil {
-				return 0, fmt.Errorf("无效的数字: %s", numStr)
-			}
-
-			return int64(num * float64(multiplier)), nil
+	// fatal 报告错误并退出，启用了进度事件流时先写入结束事件
+	fatal := func(st *scanner.Stats, err error) {
+		if sink.progress != nil {
+			sink.progress.done(st, false, err.Error())
 		}
+		log.Fatalf("%v", err)
 	}
-
-	// 没有单位后缀，直接解析为字节数
-	num, err := strconv.ParseInt(sizeStr, 10, 64)
+	scanOptions, err := sp.options(sink)
 	if err != nil {
-		return 0, fmt.Errorf("无效的大小格式: %s (支持格式: 100M, 1.5G, 1024)", sizeStr)
+		fatal(nil, err)
 	}
-
-	return num, nil
-}
-
-func main() {
-	// 命令行参数
-	rootPath := flag.String("path", ".", "扫描的根目录路径")
-	minSizeStr := flag.String("min", "0", "最小文件大小 (支持: 100M, 1.5G, 1024 等)")
-	maxSizeStr := flag.String("max", "0", "最大文件大小 (支持: 100M, 1.5G, 1024 等), 0表示不限制")
-	workers := flag.Int("workers", runtime.NumCPU()*4, "并发工作协程数")
-	showTree := flag.Bool("tree", false, "显示文件树结构")
-	treeDepth := flag.Int("depth", 0, "文件树显示深度，0表示不限制（默认不限制）")
-	outputFile := flag.String("output", "", "输出文件路径（JSON Lines格式），实时写入防止数据丢失")
-	showErrors := flag.Bool("errors", false, "显示错误详情")
-	excludePaths := flag.String("exclude", "", "要排除的路径，多个路径用逗号分隔（例如: /Volumes/ExtDisk,/private/tmp）")
-	includeExts := flag.String("include-ext", "", "只包含的文件扩展名，多个用逗号分隔（例如: .txt,.log,.md）")
-	excludeExts := flag.String("exclude-ext", "", "要排除的文件扩展名，多个用逗号分隔（例如: .tmp,.cache）")
-	namePattern := flag.String("name", "", "文件名正则表达式过滤（例如: ^test.*\\.go$）")
-	progressFile := flag.String("progress-file", "", "输出JSON格式的进度信息到指定文件（供APP调用）")
-
-	flag.Parse()
-
-	// 解析文件大小参数
-	minSize, err := parseSize(*minSizeStr)
+	s, err := scanner.New(scanOptions)
 	if err != nil {
-		log.Fatalf("最小文件大小参数错误: %v", err)
+		fatal(nil, err)
 	}
-
-	maxSize, err := parseSize(*maxSizeStr)
-	if err != nil {
-		log.Fatalf("最大文件大小参数错误: %v", err)
+	options := s.Options()
+	sink.workers = options.WorkerCount
+	if _, used, _, err := scanner.DiskUsage(options.RootPath); err == nil {
+		sink.diskUsedSize = used
 	}
+	printScanInfo(console, options)
 
-	// 验证路径
-	absPath, err := filepath.Abs(*rootPath)
-	if err != nil {
-		log.Fatalf("路径错误: %v", err)
+	// 低负载模式：在开始扫描之前降低整个进程的优先级，之后创建的线程会继承
+	if options.Nice {
+		if err := lowerPriority(); err != nil {
+			fmt.Fprintf(console, "⚠️  %v\n", err)
+		}
 	}
 
-	info, err := os.Stat(absPath)
+	// 收到 Ctrl+C 或 SIGTERM 时停止扫描：正在扫描的目录完成后保存检查点，并在输出文件末尾写入未完成的结束记录
+	// 再次收到信号时按默认方式立即退出
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		signal.Stop(sigs)
+		fmt.Fprintf(os.Stderr, "\n⏹️  收到 %v 信号，正在停止扫描并保存结果（再次按 Ctrl+C 立即退出）...\n", sig)
+		cancel(&signalError{sig.(syscall.Signal)})
+	}()
+
+	// 执行扫描
+	stats, err := s.Scan(ctx)
+	if errors.Is(err, context.Canceled) {
+		sink.finish(false)
+		cause := context.Cause(ctx)
+		printStats(console, options, stats, cause)
+		printErrorReport(console, s.ErrorReport())
+		printRootTotals(console, stats.Roots)
+		if options.OutputFile != "" && options.OutputFile != "-" {
+			fmt.Fprintf(console, "📝 已扫描的结果保存在 %s，末尾的结束记录标记为未完成\n", options.OutputFile)
+			if options.CheckpointInterval > 0 {
+				fmt.Fprintln(console, "🔁 使用相同参数加 -resume 可从检查点继续扫描")
+			}
+		}
+		code := 1
+		var se *signalError
+		if errors.As(cause, &se) {
+			code = 128 + int(se.sig)
+		}
+		if sink.progress != nil {
+			sink.progress.done(stats, false, cause.Error())
+		}
+		os.Exit(code)
+	}
 	if err != nil {
-		log.Fatalf("无法访问路径 %s: %v", absPath, err)
+		fatal(stats, fmt.Errorf("扫描失败: %v", err))
 	}
+	sink.finish(true)
+	fmt.Fprintln(console, "所有扫描任务已完成")
+	printStats(console, options, stats, nil)
+	printErrorReport(console, s.ErrorReport())
+	printRootTotals(console, stats.Roots)
+	printMountTotals(console, s.MountTotals())
+	printOwnerTotals(console, s.OwnerTotals())
+	fmt.Fprintln(console, "════════════════════════════════════════")
 
-	if !info.IsDir() {
-		log.Fatalf("%s 不是一个目录", absPath)
+	// 显示文件树
+	if sp.ShowTree {
+		printTree(console, s.GetFileTree(), sp.TreeDepth)
 	}
 
-	// 解析排除路径
-	var excludeList []string
-	if *excludePaths != "" {
-		paths := strings.Split(*excludePaths, ",")
-		for _, p := range paths {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				// 转换为绝对路径
-				absExclude, err := filepath.Abs(p)
-				if err != nil {
-					log.Printf("警告: 无法解析排除路径 %s: %v", p, err)
-					continue
-				}
-				excludeList = append(excludeList, absExclude)
-
-				// 同时获取真实路径（解析符号链接）
-				// 这样可以同时排除 /Volumes/XXX 和 /System/Volumes/Data/Volumes/XXX
-				realPath, err := filepath.EvalSymlinks(absExclude)
-				if err == nil && realPath != absExclude {
-					excludeList = append(excludeList, realPath)
-					log.Printf("排除路径: %s (实际: %s)", absExclude, realPath)
-				}
-			}
+	// 显示最大文件/目录排行
+	if sp.TopCount > 0 {
+		if err := printTopReport(console, s.TopReport(), sp.TopOutput); err != nil {
+			fatal(stats, err)
 		}
 	}
 
-	// 解析扩展名列表
-	var includeExtList []string
-	if *includeExts != "" {
-		exts := strings.Split(*includeExts, ",")
-		for _, ext := range exts {
-			ext = strings.TrimSpace(ext)
-			if ext != "" {
-				// 确保扩展名以 . 开头
-				if !strings.HasPrefix(ext, ".") {
-					ext = "." + ext
-				}
-				includeExtList = append(includeExtList, ext)
-			}
+	// 符号链接报告
+	if sp.FollowSymlinks {
+		if err := printSymlinkReport(console, s.SymlinkReport(), sp.SymlinkReport); err != nil {
+			fatal(stats, err)
 		}
 	}
 
-	var excludeExtList []string
-	if *excludeExts != "" {
-		exts := strings.Split(*excludeExts, ",")
-		for _, ext := range exts {
-			ext = strings.TrimSpace(ext)
-			if ext != "" {
-				// 确保扩展名以 . 开头
-				if !strings.HasPrefix(ext, ".") {
-					ext = "." + ext
-				}
-				excludeExtList = append(excludeExtList, ext)
-			}
+	// 查找重复文件
+	if sp.FindDupes {
+		fmt.Fprintln(console, "\n🧬 正在查找重复文件...")
+		if sink.progress != nil {
+			sink.progress.send(progressEvent{Event: "progress", Progress: scanner.Progress{
+				Phase:     phaseDupes,
+				Elapsed:   stats.Duration.Seconds(),
+				DirCount:  stats.Dirs,
+				FileCount: stats.Files,
+				TotalDisk: stats.TotalDisk,
+			}})
+		}
+		if err := printDupeReport(console, s.FindDuplicates(), sp.DupesOutput); err != nil {
+			fatal(stats, err)
 		}
 	}
 
-	// 创建扫描器
-	scanner := NewScanner(ScanOptions{
-		RootPath:     absPath,
-		MinSize:      minSize,
-		MaxSize:      maxSize,
-		WorkerCount:  *workers,
-		OutputFile:   *outputFile,
-		ShowErrors:   *showErrors,
-		ExcludePaths: excludeList,
-		IncludeExts:  includeExtList,
-		ExcludeExts:  excludeExtList,
-		NamePattern:  *namePattern,
-		ProgressFile: *progressFile,
-	})
-
-	// 执行扫描
-	if err := scanner.Scan(); err != nil {
-		log.Fatalf("扫描失败: %v", err)
-	}
-
-	// 显示文件树
-	if *showTree {
-		scanner.PrintTree(*treeDepth)
+	if sink.progress != nil {
+		sink.progress.done(stats, true, "completed")
 	}
 }
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// lowerPriority 降低 CPU 优先级（nice 10）
+// macOS 的磁盘 IO 限流需要 setiopolicy_np（cgo），这里只调整 CPU 优先级，IO 靠 -max-iops 控制
+func lowerPriority() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 10); err != nil {
+		return fmt.Errorf("无法降低优先级: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioClassIdle = 3  // IOPRIO_CLASS_IDLE：只在磁盘空闲时处理本进程的 IO
+	ioprioClassBits = 13 // IOPRIO_CLASS_SHIFT
+	ioprioWhoThread = 1  // IOPRIO_WHO_PROCESS（对 Linux 而言是线程 id）
+)
+
+// lowerPriority 降低 CPU 优先级（nice 10）并把 IO 调度类设为 idle
+// Linux 的 nice 值和 IO 优先级都是按线程设置的，需要逐个设置已有线程，之后创建的线程会继承
+func lowerPriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("无法降低优先级: %v", err)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		syscall.Setpriority(syscall.PRIO_PROCESS, tid, 10)
+		unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoThread, uintptr(tid), ioprioClassIdle<<ioprioClassBits)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// lowerPriority 降低 CPU 优先级（nice 10）
+func lowerPriority() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 10); err != nil {
+		return fmt.Errorf("无法降低优先级: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+)
+
+// consoleSink 命令行的扫描输出：在终端显示进度条（或写入进度文件），打印错误和提示信息
+type consoleSink struct {
+	out          io.Writer
+	showErrors   bool            // 显示所有错误（否则只显示 too many open files）
+	progress     *progressStream // 进度事件流（供APP或脚本读取），设置后不显示文本进度条
+	diskUsedSize int64           // 磁盘已使用空间，大于 0 时显示进度条
+	workers      int             // worker 总数（低负载模式显示当前上限时使用）
+}
+
+func (c *consoleSink) Entry(node *scanner.FileNode) {}
+
+// Progress 显示扫描进度
+func (c *consoleSink) Progress(p scanner.Progress) {
+	// 启用了进度事件流时输出进度事件，不显示文本进度条
+	if c.progress != nil {
+		c.progress.send(progressEvent{Event: "progress", Progress: p})
+		return
+	}
+	// 遍历结束后的阶段另有提示信息
+	if p.Phase != scanner.PhaseScanning {
+		return
+	}
+
+	stats := fmt.Sprintf("⏱️  %.0fs | 📁 %s (%s/s) | 📄 %s (%s/s) | 💿 %s (%s/s)",
+		p.Elapsed,
+		scanner.FormatNumber(p.DirCount),
+		scanner.FormatNumber(int64(p.DirSpeed)),
+		scanner.FormatNumber(p.FileCount),
+		scanner.FormatNumber(int64(p.FileSpeed)),
+		scanner.FormatSize(p.TotalDisk),
+		scanner.FormatSpeed(p.DiskSpeed))
+
+	// 清除当前行并显示进度
+	if p.DiskUsedSize > 0 && p.TotalDisk > 0 {
+		// 显示进度条版本，之后上移一行以便下次覆盖进度条
+		fmt.Fprintf(c.out, "\r\033[K%s\n\r\033[K%s", progressBar(p.Percentage), stats)
+		fmt.Fprint(c.out, "\033[1A")
+	} else {
+		// 没有磁盘总空间信息，只显示统计
+		fmt.Fprintf(c.out, "\r\033[K%s", stats)
+	}
+
+	if p.ErrorCount > 0 {
+		fmt.Fprintf(c.out, " | ⚠️  %d", p.ErrorCount)
+	}
+	if p.Workers > 0 {
+		fmt.Fprintf(c.out, " | 🐢 %d/%d", p.Workers, c.workers)
+	}
+}
+
+// Error 显示错误详情（"too many open files" 错误总是显示，即使没有 -errors 参数）
+func (c *consoleSink) Error(err error) {
+	if c.showErrors || errors.Is(err, syscall.EMFILE) {
+		fmt.Fprintf(os.Stderr, "\n⚠️  %v\n", err)
+	}
+}
+
+// Notice 显示提示信息（覆盖当前的进度行）
+func (c *consoleSink) Notice(msg string) {
+	fmt.Fprintf(c.out, "\r\033[K%s\n", msg)
+}
+
+// finish 清除进度显示，complete 为 true 时显示100%完成的进度条
+func (c *consoleSink) finish(complete bool) {
+	if c.progress != nil {
+		return // 没有显示文本进度条
+	}
+	if c.diskUsedSize > 0 {
+		// 清除进度条和统计行
+		fmt.Fprint(c.out, "\r\033[K\033[1B\r\033[K")
+		if complete {
+			fmt.Fprintln(c.out, progressBar(100))
+		}
+	} else {
+		fmt.Fprint(c.out, "\r\033[K")
+	}
+}
+
+// progressBar 生成40个字符宽的进度条
+func progressBar(percentage float64) string {
+	const barWidth = 40
+	filledWidth := int(percentage / 100 * barWidth)
+	if filledWidth > barWidth {
+		filledWidth = barWidth
+	}
+	return fmt.Sprintf("[%s%s] %.1f%%", strings.Repeat("█", filledWidth), strings.Repeat("░", barWidth-filledWidth), percentage)
+}
+
+// phaseDupes 扫描完成后查找重复文件的阶段（-dupes）
+const phaseDupes = "dupes"
+
+// progressEvent 进度事件
+type progressEvent struct {
+	Event string `json:"event"` // progress
+	scanner.Progress
+}
+
+// doneEvent 结束事件，是进度事件流的最后一行
+type doneEvent struct {
+	Event         string           `json:"event"`    // done
+	Complete      bool             `json:"complete"` // 扫描完整结束
+	Reason        string           `json:"reason"`   // completed 或中断、失败的原因
+	Elapsed       float64          `json:"elapsed"`
+	DirCount      int64            `json:"dirCount"`
+	FileCount     int64            `json:"fileCount"`
+	TotalSize     int64            `json:"totalSize"`
+	TotalDisk     int64            `json:"totalDisk"`
+	ErrorCount    int64            `json:"errorCount"`
+	ErrorsByErrno map[string]int64 `json:"errorsByErrno,omitempty"`
+}
+
+// progressStream 以 NDJSON（每行一个 JSON 对象）输出进度事件，打开一次持续写入，读取方可以实时逐行解析
+// 扫描器的进度协程和主流程都会写入，mu 保证每行完整，done 之后不再写入
+type progressStream struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // 需要关闭的文件或连接，标准错误不关闭
+	failed bool      // 写入失败（读取方已退出），之后不再写入
+	closed bool      // 已写入结束事件
+}
+
+// openProgressStream 打开进度事件流：stderr 为标准错误，fd:N 为已打开的文件描述符（如管道），
+// unix:PATH 连接到 Unix socket，其他值作为文件路径（每次扫描重新写入）
+func openProgressStream(target string) (*progressStream, error) {
+	switch {
+	case target == "stderr":
+		return &progressStream{w: os.Stderr}, nil
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("无效的进度输出 %s: 文件描述符应为非负整数", target)
+		}
+		f := os.NewFile(uintptr(fd), target)
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("无效的进度输出 %s: %v", target, err)
+		}
+		return &progressStream{w: f, closer: f}, nil
+	case strings.HasPrefix(target, "unix:"):
+		conn, err := net.Dial("unix", strings.TrimPrefix(target, "unix:"))
+		if err != nil {
+			return nil, fmt.Errorf("无法连接进度输出 %s: %v", target, err)
+		}
+		return &progressStream{w: conn, closer: conn}, nil
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建进度文件: %v", err)
+	}
+	return &progressStream{w: f, closer: f}, nil
+}
+
+// send 写入一个事件（一行 JSON），读取方需要实时看到，所以不缓冲
+func (ps *progressStream) send(event interface{}) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.write(event)
+}
+
+// write 写入一行事件（需持有 mu）
+func (ps *progressStream) write(event interface{}) {
+	if ps.failed || ps.closed {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if _, err := ps.w.Write(append(line, '\n')); err != nil {
+		ps.failed = true
+	}
+}
+
+// done 写入结束事件并关闭事件流，st 为 nil 表示扫描未能开始或失败；之后的事件（包括再次调用 done）都被忽略
+func (ps *progressStream) done(st *scanner.Stats, complete bool, reason string) {
+	e := doneEvent{Event: "done", Complete: complete, Reason: reason}
+	if st != nil {
+		e.Elapsed = st.Duration.Seconds()
+		e.DirCount = st.Dirs
+		e.FileCount = st.Files
+		e.TotalSize = st.TotalSize
+		e.TotalDisk = st.TotalDisk
+		e.ErrorCount = st.Errors
+		e.ErrorsByErrno = st.ErrorsByErrno
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.write(e)
+	if !ps.closed && ps.closer != nil {
+		ps.closer.Close()
+	}
+	ps.closed = true
+}
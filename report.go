@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+)
+
+// printTree 打印文件树（限制深度避免输出过多）
+func printTree(w io.Writer, root *scanner.FileNode, maxDepth int) {
+	if maxDepth > 0 {
+		fmt.Fprintf(w, "\n文件树结构 (显示深度: %d 层):\n", maxDepth)
+	} else {
+		fmt.Fprintln(w, "\n文件树结构 (完整):")
+	}
+	printNode(w, root, "", 0, maxDepth)
+}
+
+// printNode 递归打印节点
+func printNode(w io.Writer, node *scanner.FileNode, prefix string, depth, maxDepth int) {
+	// maxDepth <= 0 表示不限制深度
+	if maxDepth > 0 && depth > maxDepth {
+		return
+	}
+
+	icon := "📄"
+	if node.IsDir {
+		icon = "📁"
+	}
+
+	sizeStr := ""
+	if node.IsDir {
+		// 目录显示汇总的磁盘占用、逻辑大小和文件数
+		sizeStr = fmt.Sprintf(" (💿 %s / 💾 %s, %s 个文件)", scanner.FormatSize(node.DiskUsage), scanner.FormatSize(node.Size), scanner.FormatNumber(node.FileCount))
+	} else if node.IsSparse && node.DiskUsage < node.Size {
+		// 稀疏文件显示两个大小
+		sizeStr = fmt.Sprintf(" (💿 %s / 💾 %s)", scanner.FormatSize(node.DiskUsage), scanner.FormatSize(node.Size))
+	} else {
+		sizeStr = fmt.Sprintf(" (%s)", scanner.FormatSize(node.Size))
+	}
+
+	fmt.Fprintf(w, "%s%s %s%s\n", prefix, icon, node.Name, sizeStr)
+
+	children := node.ChildNodes()
+	if node.IsDir && len(children) > 0 {
+		childCount := len(children)
+
+		for i := 0; i < childCount; i++ {
+			child := children[i]
+			isLast := i == childCount-1
+			var newPrefix string
+			if isLast {
+				newPrefix = prefix + "└── "
+			} else {
+				newPrefix = prefix + "├── "
+			}
+			printNode(w, child, newPrefix, depth+1, maxDepth)
+		}
+	}
+}
+
+// printMountTotals 打印各挂载点的合计（只经过一个挂载点时不打印）
+func printMountTotals(w io.Writer, totals []scanner.MountTotal) {
+	if len(totals) < 2 {
+		return
+	}
+	fmt.Fprintln(w, "💽 各挂载点:")
+	for _, t := range totals {
+		fmt.Fprintf(w, "   %-30s %-10s 📄 %-12s 💿 %s\n", t.MountPoint, t.FsType, scanner.FormatNumber(t.Files), scanner.FormatSize(t.DiskUsage))
+	}
+}
+
+// printRootTotals 打印各扫描根目录的合计（只有一个根目录时不打印）
+func printRootTotals(w io.Writer, totals []scanner.RootTotal) {
+	if len(totals) < 2 {
+		return
+	}
+	fmt.Fprintln(w, "📂 各根目录:")
+	for _, t := range totals {
+		line := fmt.Sprintf("   %-30s 📁 %-10s 📄 %-12s 💿 %s", t.Path, scanner.FormatNumber(t.Dirs), scanner.FormatNumber(t.Files), scanner.FormatSize(t.TotalDisk))
+		if t.Errors > 0 {
+			line += fmt.Sprintf(" ⚠️  %d", t.Errors)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// printOwnerTotals 打印各用户的占用
+func printOwnerTotals(w io.Writer, totals []scanner.OwnerTotal) {
+	if len(totals) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "👤 各用户占用:")
+	for _, t := range totals {
+		fmt.Fprintf(w, "   %-16s %-8d 📄 %-12s 💿 %s\n", t.User, t.Uid, scanner.FormatNumber(t.Files), scanner.FormatSize(t.DiskUsage))
+	}
+}
+
+// printErrorReport 按 errno 和目录打印错误统计（没有错误时不打印）
+func printErrorReport(w io.Writer, report *scanner.ErrorReport) {
+	if report == nil || report.Total == 0 {
+		return
+	}
+	fmt.Fprintln(w, "🚫 错误分类:")
+	for _, c := range report.ByErrno {
+		fmt.Fprintf(w, "   %-14s %s\n", c.Errno, scanner.FormatNumber(c.Count))
+	}
+	fmt.Fprintln(w, "📂 错误最多的目录:")
+	for _, d := range report.TopDirs {
+		fmt.Fprintf(w, "   %8s  %s (%s)\n", scanner.FormatNumber(d.Count), d.Path, strings.Join(d.Errnos, ", "))
+	}
+}
+
+// printTopReport 打印排行，outputPath 不为空时同时写入 JSON 报告
+func printTopReport(w io.Writer, report *scanner.TopReport, outputPath string) error {
+	fmt.Fprint(w, "\n")
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	fmt.Fprintf(w, "🏆 占用空间最大的 %d 个文件\n", report.Limit)
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	for _, f := range report.LargestFiles {
+		fmt.Fprintf(w, "%12s  %s\n", scanner.FormatSize(f.DiskUsage), f.Path)
+	}
+
+	fmt.Fprint(w, "\n")
+	fmt.Fprintf(w, "📂 占用空间最大的 %d 个目录\n", report.Limit)
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	for _, d := range report.LargestDirs {
+		fmt.Fprintf(w, "%12s  %s (%s 个文件)\n", scanner.FormatSize(d.DiskUsage), d.Path, scanner.FormatNumber(d.FileCount))
+	}
+
+	fmt.Fprint(w, "\n")
+	fmt.Fprintf(w, "🕰️  最旧的 %d 个大文件 (≥ %s)\n", report.Limit, scanner.FormatSize(report.OldMinSize))
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	for _, f := range report.OldestLargeFiles {
+		fmt.Fprintf(w, "%s  %12s  %s\n", time.Unix(f.ModTime, 0).Format("2006-01-02"), scanner.FormatSize(f.DiskUsage), f.Path)
+	}
+	fmt.Fprintln(w, "════════════════════════════════════════")
+
+	if outputPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("无法写入排行报告: %v", err)
+	}
+	fmt.Fprintf(w, "📝 排行报告: %s\n", outputPath)
+	return nil
+}
+
+// printSymlinkReport 打印符号链接报告，outputPath 不为空时同时写入 JSON 报告
+func printSymlinkReport(w io.Writer, report *scanner.SymlinkReport, outputPath string) error {
+	if len(report.Broken) > 0 || len(report.Escaping) > 0 {
+		fmt.Fprint(w, "\n")
+		fmt.Fprintln(w, "════════════════════════════════════════")
+		fmt.Fprintf(w, "💔 断开的符号链接: %d\n", len(report.Broken))
+		fmt.Fprintln(w, "════════════════════════════════════════")
+		for _, l := range report.Broken {
+			fmt.Fprintf(w, "%s -> %s\n", l.Path, l.Target)
+		}
+
+		fmt.Fprint(w, "\n")
+		fmt.Fprintf(w, "↗️  指向扫描目录之外的符号链接: %d\n", len(report.Escaping))
+		fmt.Fprintln(w, "════════════════════════════════════════")
+		for _, l := range report.Escaping {
+			fmt.Fprintf(w, "%s -> %s\n", l.Path, l.Resolved)
+		}
+		fmt.Fprintln(w, "════════════════════════════════════════")
+	}
+
+	if outputPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("无法写入符号链接报告: %v", err)
+	}
+	fmt.Fprintf(w, "📝 符号链接报告: %s\n", outputPath)
+	return nil
+}
+
+// printDupeReport 显示重复文件统计，并输出 JSON 报告（未指定文件时输出到标准输出）
+func printDupeReport(w io.Writer, report *scanner.DupeReport, outputPath string) error {
+	fmt.Fprint(w, "\n")
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	fmt.Fprintln(w, "🧬 重复文件")
+	fmt.Fprintln(w, "════════════════════════════════════════")
+	fmt.Fprintf(w, "📦 重复组数: %s\n", scanner.FormatNumber(int64(report.GroupCount)))
+	fmt.Fprintf(w, "📄 多余副本: %s\n", scanner.FormatNumber(report.DuplicateFiles))
+	fmt.Fprintf(w, "💿 可回收: %s\n", scanner.FormatSize(report.ReclaimableBytes))
+	fmt.Fprintln(w, "════════════════════════════════════════")
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if outputPath == "" {
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("无法写入重复文件报告: %v", err)
+	}
+	fmt.Fprintf(w, "📝 重复文件报告: %s\n", outputPath)
+	return nil
+}
@@ -0,0 +1,494 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	checkpointVersion = 1
+
+	// inode key 日志中的前缀，区分目录去重和硬链接去重
+	dirKeyPrefix      = "d "
+	hardlinkKeyPrefix = "h "
+)
+
+// checkpointState 检查点文件内容
+// 输出文件中 OutputOffset 之前的记录全部来自已提交的目录，Pending 是已入队但尚未提交的目录，
+// 恢复时截断到 OutputOffset 并重新扫描 Pending 即可继续，不会产生重复记录
+type checkpointState struct {
+	Version      int                `json:"version"`
+	RootPath     string             `json:"root_path"`
+	Roots        []RootTotal        `json:"roots,omitempty"`         // 扫描多个根目录时各根目录的路径和合计
+	StartTime    int64              `json:"start_time"`              // 首次开始扫描的时间
+	SavedAt      int64              `json:"saved_at"`                // 检查点写入时间
+	OutputOffset int64              `json:"output_offset"`           // 输出文件中已提交内容的长度
+	KeysOffset   int64              `json:"keys_offset"`             // inode key 日志中已提交内容的长度
+	ErrorsFile   string             `json:"errors_file,omitempty"`   // 错误文件的绝对路径
+	ErrorsOffset int64              `json:"errors_offset,omitempty"` // 错误文件中已提交内容的长度
+	ErrorDirs    []ErrorDir         `json:"error_dirs,omitempty"`    // 已提交的错误按目录的统计
+	Pending      []string           `json:"pending"`                 // 尚未完成的目录
+	Counters     checkpointCounters `json:"counters"`
+	Mounts       []MountTotal       `json:"mounts,omitempty"`   // 各挂载点的合计
+	Symlinks     *SymlinkReport     `json:"symlinks,omitempty"` // 已发现的断开链接和指向扫描根目录之外的链接
+	Owners       []OwnerTotal       `json:"owners,omitempty"`   // 各用户的合计
+}
+
+// checkpointCounters 检查点时刻的统计数据
+type checkpointCounters struct {
+	Files      int64 `json:"files"`
+	Dirs       int64 `json:"dirs"`
+	Size       int64 `json:"size"`
+	Disk       int64 `json:"disk"`
+	Sparse     int64 `json:"sparse"`
+	Hardlinks  int64 `json:"hardlinks"`
+	Symlinks   int64 `json:"symlinks"`
+	DupDirs    int64 `json:"dup_dirs"`
+	Excluded   int64 `json:"excluded"`
+	Errors     int64 `json:"errors"`
+	HashErrors int64 `json:"hash_errors"`
+	ReusedDirs int64 `json:"reused_dirs"`
+
+	SkippedMounts int64            `json:"skipped_mounts,omitempty"`
+	DepthLimited  int64            `json:"depth_limited,omitempty"`
+	ErrorsByErrno map[string]int64 `json:"errors_by_errno,omitempty"` // 已提交的各 errno 错误数
+}
+
+// checkpointer 维护检查点所需的状态
+// commit 在 outputMu 保护下调用
+type checkpointer struct {
+	path       string              // 检查点文件路径
+	keysFile   *os.File            // inode key 日志（只追加）
+	keysWriter *bufio.Writer       // inode key 日志缓冲
+	keysOffset int64               // 已写入 inode key 日志的字节数
+	pending    map[string]struct{} // 已入队但尚未提交的目录
+	startTime  int64               // 首次开始扫描的时间
+}
+
+// checkpointPath 返回输出文件对应的检查点文件路径
+func checkpointPath(outputFile string) string {
+	return outputFile + ".checkpoint"
+}
+
+// checkpointKeysPath 返回输出文件对应的 inode key 日志路径
+func checkpointKeysPath(outputFile string) string {
+	return outputFile + ".checkpoint-keys"
+}
+
+// commit 记录一个目录已完成，其子目录进入待扫描集合
+func (c *checkpointer) commit(b *dirBatch) {
+	delete(c.pending, b.dirPath)
+	for _, path := range b.subdirs {
+		c.pending[path] = struct{}{}
+	}
+	for _, key := range b.keys {
+		n, _ := c.keysWriter.WriteString(key + "\n")
+		c.keysOffset += int64(n)
+	}
+}
+
+// newCheckpointer 为新的扫描创建检查点（清空旧的 inode key 日志）
+func (s *Scanner) newCheckpointer() error {
+	keysFile, err := os.Create(checkpointKeysPath(s.options.OutputFile))
+	if err != nil {
+		return fmt.Errorf("无法创建检查点文件: %v", err)
+	}
+
+	s.checkpoint = &checkpointer{
+		path:       checkpointPath(s.options.OutputFile),
+		keysFile:   keysFile,
+		keysWriter: bufio.NewWriter(keysFile),
+		pending:    make(map[string]struct{}, len(s.roots)),
+		startTime:  time.Now().Unix(),
+	}
+	for _, r := range s.roots {
+		s.checkpoint.pending[r.path] = struct{}{}
+	}
+	return nil
+}
+
+// resumeFromCheckpoint 从检查点恢复扫描状态，返回需要继续扫描的目录
+// 输出文件会被截断到检查点位置并以追加方式继续写入
+func (s *Scanner) resumeFromCheckpoint() ([]string, error) {
+	path := checkpointPath(s.options.OutputFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取检查点文件 %s: %v", path, err)
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("检查点文件格式错误: %v", err)
+	}
+	if state.Version != checkpointVersion {
+		return nil, fmt.Errorf("不支持的检查点版本: %d", state.Version)
+	}
+	stateRoots := []string{state.RootPath}
+	if len(state.Roots) > 0 {
+		stateRoots = stateRoots[:0]
+		for _, t := range state.Roots {
+			stateRoots = append(stateRoots, t.Path)
+		}
+	}
+	if strings.Join(stateRoots, "\n") != strings.Join(s.options.RootPaths, "\n") {
+		return nil, fmt.Errorf("检查点的扫描路径 %s 与当前扫描路径 %s 不一致", strings.Join(stateRoots, ", "), strings.Join(s.options.RootPaths, ", "))
+	}
+	// 错误文件中检查点之前的记录与检查点的错误统计一致，需要继续写入同一个错误文件
+	errorsFile, err := absErrorsFile(s.options.ErrorsFile)
+	if err != nil {
+		return nil, err
+	}
+	if errorsFile != state.ErrorsFile {
+		return nil, fmt.Errorf("检查点的错误文件 %q 与当前的错误文件 %q 不一致，恢复扫描时 -errors-file 需要与中断的扫描相同", state.ErrorsFile, errorsFile)
+	}
+
+	// 截断输出文件，丢弃检查点之后未确认的记录
+	f, err := os.OpenFile(s.options.OutputFile, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开输出文件: %v", err)
+	}
+	if err := truncateTo(f, state.OutputOffset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("输出文件与检查点不一致: %v", err)
+	}
+
+	// 重放已写入的记录，扫描结束后的目录汇总、排行和文件树才完整
+	if err := s.restoreFromOutput(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// 重建去重状态
+	keysFile, err := os.OpenFile(checkpointKeysPath(s.options.OutputFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("无法打开检查点文件: %v", err)
+	}
+	if err := truncateTo(keysFile, state.KeysOffset); err != nil {
+		f.Close()
+		keysFile.Close()
+		return nil, fmt.Errorf("检查点 inode 日志不完整: %v", err)
+	}
+	if err := s.loadCheckpointKeys(keysFile); err != nil {
+		f.Close()
+		keysFile.Close()
+		return nil, err
+	}
+
+	c := state.Counters
+	s.fileCount.Store(c.Files)
+	s.dirCount.Store(c.Dirs)
+	s.totalSize.Store(c.Size)
+	s.totalDisk.Store(c.Disk)
+	s.sparseCount.Store(c.Sparse)
+	s.hardlinkCount.Store(c.Hardlinks)
+	s.symlinkCount.Store(c.Symlinks)
+	s.dupDirCount.Store(c.DupDirs)
+	s.excludedCount.Store(c.Excluded)
+	s.errorCount.Store(c.Errors)
+	s.hashErrorCount.Store(c.HashErrors)
+	s.reusedDirCount.Store(c.ReusedDirs)
+	s.skippedMounts.Store(c.SkippedMounts)
+	s.depthLimited.Store(c.DepthLimited)
+	if err := s.errors.resume(s.options.ErrorsFile, state.ErrorsOffset, c.ErrorsByErrno, state.ErrorDirs); err != nil {
+		f.Close()
+		keysFile.Close()
+		return nil, err
+	}
+	s.mounts.restore(state.Mounts)
+	s.restoreRoots(state.Roots)
+	if state.Symlinks != nil {
+		s.links = *state.Symlinks
+	}
+	for i := range state.Owners {
+		t := state.Owners[i]
+		s.owners[t.Uid] = &t
+	}
+
+	if s.agg != nil {
+		s.agg.finishReplay(state.Pending)
+	}
+
+	pending := make(map[string]struct{}, len(state.Pending))
+	for _, dir := range state.Pending {
+		pending[dir] = struct{}{}
+	}
+
+	if err := s.setOutput(f); err != nil {
+		f.Close()
+		keysFile.Close()
+		return nil, err
+	}
+	s.checkpoint = &checkpointer{
+		path:       path,
+		keysFile:   keysFile,
+		keysWriter: bufio.NewWriter(keysFile),
+		keysOffset: state.KeysOffset,
+		pending:    pending,
+		startTime:  state.StartTime,
+	}
+
+	s.sink.Notice(fmt.Sprintf("🔄 从检查点恢复: %s (保存于 %s)\n   已完成: 📁 %s | 📄 %s | 待扫描目录: %s",
+		path, time.Unix(state.SavedAt, 0).Format("2006-01-02 15:04:05"),
+		FormatNumber(c.Dirs), FormatNumber(c.Files), FormatNumber(int64(len(state.Pending)))))
+
+	return state.Pending, nil
+}
+
+// loadCheckpointKeys 从 inode key 日志重建目录和硬链接去重状态
+func (s *Scanner) loadCheckpointKeys(keysFile *os.File) error {
+	if _, err := keysFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("读取检查点 inode 日志失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(keysFile)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, dirKeyPrefix):
+			s.dirInodeMap.Store(strings.TrimPrefix(line, dirKeyPrefix), true)
+		case strings.HasPrefix(line, hardlinkKeyPrefix):
+			s.inodeMap.Store(strings.TrimPrefix(line, hardlinkKeyPrefix), true)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取检查点 inode 日志失败: %v", err)
+	}
+
+	// 后续写入追加在末尾
+	if _, err := keysFile.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("读取检查点 inode 日志失败: %v", err)
+	}
+	return nil
+}
+
+// saveCheckpoint 保存检查点
+// 在锁内获取一致的快照，落盘和写检查点文件在锁外完成，避免长时间阻塞 worker
+func (s *Scanner) saveCheckpoint() error {
+	c := s.checkpoint
+	errorsFile, err := absErrorsFile(s.options.ErrorsFile)
+	if err != nil {
+		return err
+	}
+
+	s.outputMu.Lock()
+	// 压缩输出在检查点处结束当前压缩段，恢复时截断到这里后追加新的压缩段
+	if err := s.endSegment(); err != nil {
+		s.outputMu.Unlock()
+		return err
+	}
+	offset, err := s.outputFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		s.outputMu.Unlock()
+		return fmt.Errorf("无法获取输出文件位置: %v", err)
+	}
+	if err := s.startSegment(); err != nil {
+		s.outputMu.Unlock()
+		return err
+	}
+	if err := c.keysWriter.Flush(); err != nil {
+		s.outputMu.Unlock()
+		return fmt.Errorf("写入检查点 inode 日志失败: %v", err)
+	}
+
+	state := checkpointState{
+		Version:      checkpointVersion,
+		RootPath:     s.options.RootPath,
+		Roots:        s.rootTotals(),
+		StartTime:    c.startTime,
+		SavedAt:      time.Now().Unix(),
+		OutputOffset: offset,
+		KeysOffset:   c.keysOffset,
+		ErrorsFile:   errorsFile,
+		Pending:      make([]string, 0, len(c.pending)),
+		Counters: checkpointCounters{
+			Files:      s.fileCount.Load(),
+			Dirs:       s.dirCount.Load(),
+			Size:       s.totalSize.Load(),
+			Disk:       s.totalDisk.Load(),
+			Sparse:     s.sparseCount.Load(),
+			Hardlinks:  s.hardlinkCount.Load(),
+			Symlinks:   s.symlinkCount.Load(),
+			DupDirs:    s.dupDirCount.Load(),
+			Excluded:   s.excludedCount.Load(),
+			Errors:     s.errorCount.Load(),
+			HashErrors: s.hashErrorCount.Load(),
+			ReusedDirs: s.reusedDirCount.Load(),
+
+			SkippedMounts: s.skippedMounts.Load(),
+			DepthLimited:  s.depthLimited.Load(),
+		},
+		Mounts: s.mounts.snapshot(),
+		Owners: s.ownerSnapshot(),
+	}
+	if s.options.FollowSymlinks {
+		links := s.links
+		state.Symlinks = &links
+	}
+	for dir := range c.pending {
+		state.Pending = append(state.Pending, dir)
+	}
+	state.Counters.ErrorsByErrno, state.ErrorDirs, state.ErrorsOffset = s.errors.snapshot()
+	s.outputMu.Unlock()
+
+	sort.Strings(state.Pending)
+
+	// 确保检查点引用的内容已经落盘（之后继续写入的内容不影响一致性）
+	if err := s.outputFile.Sync(); err != nil {
+		return fmt.Errorf("同步输出文件失败: %v", err)
+	}
+	if err := c.keysFile.Sync(); err != nil {
+		return fmt.Errorf("同步检查点 inode 日志失败: %v", err)
+	}
+	if err := s.errors.sync(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，保证检查点文件始终完整
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入检查点失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("写入检查点失败: %v", err)
+	}
+	return nil
+}
+
+// runCheckpoints 定期保存检查点，直到 done 被关闭
+func (s *Scanner) runCheckpoints(done chan bool) {
+	ticker := time.NewTicker(s.options.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.saveCheckpoint(); err != nil {
+				s.sink.Error(err)
+			}
+		}
+	}
+}
+
+// removeCheckpoint 扫描正常完成后删除检查点文件
+func (s *Scanner) removeCheckpoint() {
+	c := s.checkpoint
+	c.keysFile.Close()
+	os.Remove(c.path)
+	os.Remove(checkpointKeysPath(s.options.OutputFile))
+}
+
+// ensureNode 获取目录节点，不存在时连同缺失的上级目录一起创建
+// 恢复扫描时用于把待扫描目录挂回文件树
+func (s *Scanner) ensureNode(path string) *FileNode {
+	if node := s.getOrCreateNode(path); node != nil {
+		return node
+	}
+	if parentPath := filepath.Dir(path); parentPath == path {
+		// 已到达文件系统根目录仍未找到，说明路径不在扫描范围内，挂到根节点下
+		return s.root
+	}
+
+	parent := s.ensureNode(filepath.Dir(path))
+	node := &FileNode{
+		Path:     path,
+		Name:     filepath.Base(path),
+		IsDir:    true,
+		Children: make([]*FileNode, 0),
+	}
+	parent.mu.Lock()
+	parent.Children = append(parent.Children, node)
+	parent.mu.Unlock()
+	s.nodeMap.Store(path, node)
+	return node
+}
+
+// restoreFromOutput 读取输出文件中已确认的记录，登记到目录汇总和排行，构建文件树时把文件和目录挂回文件树
+func (s *Scanner) restoreFromOutput() error {
+	if s.agg == nil && s.top == nil && !s.options.BuildTree {
+		return nil
+	}
+
+	for _, r := range s.roots {
+		s.nodeMap.Store(r.path, r.node)
+	}
+	_, err := ReadScanRecords(s.options.OutputFile, func(rec *ScanRecord) error {
+		if s.top != nil && !rec.IsDir {
+			s.top.addFile(rec.Path, rec.Size, rec.DiskUsage, rec.ModTime, rec.IsHardlink)
+		}
+		if s.agg != nil {
+			s.agg.replay(rec)
+		}
+		if !s.options.BuildTree {
+			return nil
+		}
+		if rec.IsDir {
+			node := s.ensureNode(rec.Path)
+			node.ModTime = rec.ModTime
+			node.LinkTarget = rec.LinkTarget
+			return nil
+		}
+		parent := s.ensureNode(filepath.Dir(rec.Path))
+		parent.Children = append(parent.Children, &FileNode{
+			Path:        rec.Path,
+			Name:        rec.Name,
+			Size:        rec.Size,
+			DiskUsage:   rec.DiskUsage,
+			ModTime:     rec.ModTime,
+			IsSparse:    rec.IsSparse,
+			IsHardlink:  rec.IsHardlink,
+			Hash:        rec.Hash,
+			HashSampled: rec.HashSampled,
+			LinkTarget:  rec.LinkTarget,
+			InodeKey:    rec.InodeKey,
+			FileMeta:    rec.FileMeta,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("无法重放输出文件中的记录: %v", err)
+	}
+	return nil
+}
+
+// absErrorsFile 错误文件的绝对路径（写入检查点），未设置时返回空字符串
+func absErrorsFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("错误文件路径错误: %v", err)
+	}
+	return abs, nil
+}
+
+// truncateTo 将文件截断到指定长度并把写入位置移到末尾
+func truncateTo(f *os.File, size int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < size {
+		return fmt.Errorf("%s 长度 %d 小于检查点记录的 %d", f.Name(), info.Size(), size)
+	}
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	_, err = f.Seek(size, io.SeekStart)
+	return err
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 输出文件的压缩格式，由文件扩展名决定
+const (
+	compressNone = ""
+	compressGzip = "gzip" // .gz
+	compressZstd = "zstd" // .zst / .zstd
+)
+
+// 压缩格式的魔数，读取时按文件内容识别，不依赖扩展名
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressionFor 按输出文件的扩展名返回压缩格式
+func compressionFor(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return compressGzip
+	case strings.HasSuffix(path, ".zst"), strings.HasSuffix(path, ".zstd"):
+		return compressZstd
+	}
+	return compressNone
+}
+
+// compressWriter 压缩流，Flush 产生一个刷新点（之前写入的内容都可以从文件中解压出来），Close 结束当前的 gzip 成员/zstd 帧
+type compressWriter interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// newCompressWriter 创建压缩流，compression 为空时返回 nil
+// 多个 gzip 成员或 zstd 帧首尾相接仍是合法的压缩文件，追加写入时直接开始新的成员/帧
+func newCompressWriter(w io.Writer, compression string) (compressWriter, error) {
+	switch compression {
+	case compressGzip:
+		return gzip.NewWriter(w), nil
+	case compressZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, nil
+}
+
+// scanFileReader 读取扫描结果文件，压缩文件透明解压
+type scanFileReader struct {
+	io.Reader
+	file       *os.File
+	compressed bool
+	closeFn    func()
+}
+
+func (r *scanFileReader) Close() error {
+	if r.closeFn != nil {
+		r.closeFn()
+	}
+	return r.file.Close()
+}
+
+// OpenScanFile 打开扫描结果文件，gzip 和 zstd 压缩的文件按内容识别并透明解压
+// 扫描进程被强制结束时压缩流没有正常结束，读取到最后一个刷新点后按文件结束处理
+func OpenScanFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newScanFileReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("无法解压 %s: %v", path, err)
+	}
+	return r, nil
+}
+
+func newScanFileReader(f *os.File) (*scanFileReader, error) {
+	br := bufio.NewReaderSize(f, 256*1024)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &scanFileReader{Reader: truncatedReader{zr}, file: f, compressed: true}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return &scanFileReader{Reader: truncatedReader{zr}, file: f, compressed: true, closeFn: zr.Close}, nil
+	}
+	return &scanFileReader{Reader: br, file: f}, nil
+}
+
+// truncatedReader 把压缩流意外结束的错误当作文件结束，被强制中断的扫描仍可以读取已刷新的内容
+type truncatedReader struct {
+	r io.Reader
+}
+
+func (t truncatedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	dupePartialHashSize = 64 * 1024 // 部分哈希读取的字节数
+	dupeHashWorkers     = 8         // 计算哈希的并发数（IO 密集，不宜过多）
+)
+
+// dupeCandidate 重复文件候选
+type dupeCandidate struct {
+	path string
+	disk int64
+}
+
+// dupeFinder 收集扫描到的文件，扫描结束后查找重复文件
+type dupeFinder struct {
+	mu     sync.Mutex
+	bySize map[int64][]dupeCandidate
+}
+
+// DupeGroup 一组内容完全相同的文件
+type DupeGroup struct {
+	Size        int64    `json:"size"`
+	Hash        string   `json:"hash"`
+	Reclaimable int64    `json:"reclaimable"` // 每组保留一个文件时可回收的磁盘空间
+	Paths       []string `json:"paths"`
+}
+
+// DupeReport 重复文件报告
+type DupeReport struct {
+	RootPath         string      `json:"root_path"`
+	GroupCount       int         `json:"group_count"`
+	DuplicateFiles   int64       `json:"duplicate_files"` // 多余的副本数（不含每组保留的一个）
+	ReclaimableBytes int64       `json:"reclaimable_bytes"`
+	Groups           []DupeGroup `json:"groups"`
+}
+
+func newDupeFinder() *dupeFinder {
+	return &dupeFinder{bySize: make(map[int64][]dupeCandidate)}
+}
+
+// add 登记一个文件
+// 硬链接（重复的 inode）不是独立的副本，删除也无法回收空间，不参与查找
+func (d *dupeFinder) add(node *FileNode) {
+	if node.IsHardlink || node.Size == 0 {
+		return
+	}
+	d.mu.Lock()
+	d.bySize[node.Size] = append(d.bySize[node.Size], dupeCandidate{path: node.Path, disk: node.DiskUsage})
+	d.mu.Unlock()
+}
+
+// find 查找重复文件：先按大小分组，再用部分哈希筛选，最后用完整内容哈希确认
+func (d *dupeFinder) find(rootPath string) *DupeReport {
+	jobs := make(chan int64)
+	results := make(chan []DupeGroup)
+
+	var wg sync.WaitGroup
+	for i := 0; i < dupeHashWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for size := range jobs {
+				if groups := confirmDupes(size, d.bySize[size]); len(groups) > 0 {
+					results <- groups
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for size, candidates := range d.bySize {
+			if len(candidates) > 1 {
+				jobs <- size
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	report := &DupeReport{RootPath: rootPath, Groups: make([]DupeGroup, 0)}
+	for groups := range results {
+		for _, group := range groups {
+			report.Groups = append(report.Groups, group)
+			report.DuplicateFiles += int64(len(group.Paths) - 1)
+			report.ReclaimableBytes += group.Reclaimable
+		}
+	}
+	report.GroupCount = len(report.Groups)
+
+	// 可回收空间大的排在前面
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Reclaimable != report.Groups[j].Reclaimable {
+			return report.Groups[i].Reclaimable > report.Groups[j].Reclaimable
+		}
+		return report.Groups[i].Paths[0] < report.Groups[j].Paths[0]
+	})
+
+	return report
+}
+
+// confirmDupes 在大小相同的文件中确认真正的重复文件
+func confirmDupes(size int64, candidates []dupeCandidate) []DupeGroup {
+	// 第一轮：部分哈希，快速排除大部分不同的文件
+	byPartial := groupByHash(candidates, dupePartialHashSize)
+
+	var groups []DupeGroup
+	for partial, same := range byPartial {
+		if len(same) < 2 {
+			continue
+		}
+
+		// 文件不超过部分哈希大小时，部分哈希就是完整哈希
+		byFull := map[string][]dupeCandidate{partial: same}
+		if size > dupePartialHashSize {
+			byFull = groupByHash(same, 0)
+		}
+
+		for hash, dupes := range byFull {
+			if len(dupes) < 2 {
+				continue
+			}
+			sort.Slice(dupes, func(i, j int) bool { return dupes[i].path < dupes[j].path })
+
+			group := DupeGroup{Size: size, Hash: "sha256:" + hash, Paths: make([]string, 0, len(dupes))}
+			for i, dupe := range dupes {
+				group.Paths = append(group.Paths, dupe.path)
+				if i > 0 {
+					group.Reclaimable += dupe.disk
+				}
+			}
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// groupByHash 按内容哈希分组，limit > 0 时只读取前 limit 字节
+// 无法读取的文件（扫描后被删除、无权限等）直接跳过
+func groupByHash(candidates []dupeCandidate, limit int64) map[string][]dupeCandidate {
+	result := make(map[string][]dupeCandidate)
+	for _, c := range candidates {
+		hash, err := hashFileSHA256(c.path, limit)
+		if err != nil {
+			continue
+		}
+		result[hash] = append(result[hash], c)
+	}
+	return result
+}
+
+// hashFileSHA256 计算文件内容的 SHA-256，limit > 0 时只读取前 limit 字节
+func hashFileSHA256(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit > 0 {
+		r = io.LimitReader(f, limit)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FindDuplicates 查找扫描到的重复文件（需要开启 Options.FindDupes）
+func (s *Scanner) FindDuplicates() *DupeReport {
+	if s.dupes == nil {
+		return &DupeReport{RootPath: s.options.RootPath, Groups: make([]DupeGroup, 0)}
+	}
+	return s.dupes.find(s.options.RootPath)
+}
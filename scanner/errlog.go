@@ -0,0 +1,355 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// errnoOther 没有对应 errno 的错误（如忽略文件中的规则有误）
+const errnoOther = "OTHER"
+
+// errnoNames 常见的 errno 名称，其他 errno 显示为 "errno N"
+var errnoNames = map[syscall.Errno]string{
+	syscall.EPERM:        "EPERM",
+	syscall.ENOENT:       "ENOENT",
+	syscall.EINTR:        "EINTR",
+	syscall.EIO:          "EIO",
+	syscall.ENXIO:        "ENXIO",
+	syscall.EBADF:        "EBADF",
+	syscall.EAGAIN:       "EAGAIN",
+	syscall.ENOMEM:       "ENOMEM",
+	syscall.EACCES:       "EACCES",
+	syscall.EBUSY:        "EBUSY",
+	syscall.ENODEV:       "ENODEV",
+	syscall.ENOTDIR:      "ENOTDIR",
+	syscall.EINVAL:       "EINVAL",
+	syscall.ENFILE:       "ENFILE",
+	syscall.EMFILE:       "EMFILE",
+	syscall.ENOSPC:       "ENOSPC",
+	syscall.EROFS:        "EROFS",
+	syscall.ELOOP:        "ELOOP",
+	syscall.ENAMETOOLONG: "ENAMETOOLONG",
+	syscall.EOVERFLOW:    "EOVERFLOW",
+	syscall.ETIMEDOUT:    "ETIMEDOUT",
+	syscall.ESTALE:       "ESTALE",
+	syscall.EDEADLK:      "EDEADLK",
+}
+
+// Errno 返回错误对应的 errno 名称（如 EACCES）和数值，没有 errno 时返回 OTHER 和 0
+func Errno(err error) (string, int) {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return errnoOther, 0
+	}
+	if name, ok := errnoNames[errno]; ok {
+		return name, int(errno)
+	}
+	return fmt.Sprintf("errno %d", int(errno)), int(errno)
+}
+
+// ErrorRecord 错误文件（ErrorsFile）中的一条记录
+type ErrorRecord struct {
+	Time      string `json:"time"`
+	Op        string `json:"op"`
+	Path      string `json:"path"`
+	PathBytes []byte `json:"path_bytes,omitempty"` // 路径不是有效的 UTF-8 时保存原始字节
+	Dir       string `json:"dir"`                  // 按目录汇总时使用的目录
+	Errno     string `json:"errno"`
+	Code      int    `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ErrorReport 按 errno 和目录分组的错误统计
+type ErrorReport struct {
+	Total   int64        `json:"total"`
+	ByErrno []ErrnoCount `json:"by_errno"`
+	TopDirs []ErrorDir   `json:"top_dirs"` // 错误最多的目录
+}
+
+// ErrnoCount 一种 errno 的错误数
+type ErrnoCount struct {
+	Errno string `json:"errno"`
+	Count int64  `json:"count"`
+}
+
+// ErrorDir 一个目录中的错误数（目录本身无法读取，或其中的子项无法访问）
+type ErrorDir struct {
+	Path   string   `json:"path"`
+	Count  int64    `json:"count"`
+	Errnos []string `json:"errnos"`
+}
+
+// errorTopDirs 错误报告中列出的目录数
+const errorTopDirs = 10
+
+// errorLog 收集扫描中的错误：按 errno 和目录计数，设置了错误文件时写入
+// 扫描目录时的错误随目录批次提交后才写入错误文件并计入 committed，与输出文件一样，
+// 检查点之前的错误文件内容和 committed 都只来自已提交的目录，恢复扫描时截断错误文件即可继续
+type errorLog struct {
+	mu        sync.Mutex
+	live      errorStats // 已报告的全部错误（扫描中的进度和扫描结束后的报告）
+	committed errorStats // 已提交的错误，与错误文件中的记录一致（写入检查点）
+	file      *os.File   // 错误文件，nil 表示不写入
+	offset    int64      // 错误文件中已写入内容的长度
+	failed    bool       // 写入错误文件失败（只报告一次）
+}
+
+// errorStats 按 errno 和目录分组的错误计数
+type errorStats struct {
+	total   int64
+	byErrno map[string]int64
+	dirs    map[string]*errorDirStat
+}
+
+type errorDirStat struct {
+	count  int64
+	errnos map[string]struct{}
+}
+
+func newErrorStats() errorStats {
+	return errorStats{byErrno: make(map[string]int64), dirs: make(map[string]*errorDirStat)}
+}
+
+func newErrorLog() *errorLog {
+	return &errorLog{live: newErrorStats(), committed: newErrorStats()}
+}
+
+// add 计入一个错误
+func (st *errorStats) add(errno, dir string) {
+	st.total++
+	st.byErrno[errno]++
+	d := st.dirs[dir]
+	if d == nil {
+		d = &errorDirStat{errnos: make(map[string]struct{})}
+		st.dirs[dir] = d
+	}
+	d.count++
+	d.errnos[errno] = struct{}{}
+}
+
+// create 创建错误文件（每次扫描重新写入）
+func (l *errorLog) create(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("无法创建错误文件: %v", err)
+	}
+	l.file = f
+	return nil
+}
+
+// resume 恢复扫描时以追加方式打开错误文件，截断到检查点位置（丢弃检查点之后写入的记录），
+// 并从检查点恢复已提交的错误统计
+func (l *errorLog) resume(path string, offset int64, counts map[string]int64, dirs []ErrorDir) error {
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("无法打开错误文件: %v", err)
+		}
+		if err := truncateTo(f, offset); err != nil {
+			f.Close()
+			return fmt.Errorf("错误文件与检查点不一致: %v", err)
+		}
+		l.file = f
+		l.offset = offset
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, st := range []*errorStats{&l.live, &l.committed} {
+		for name, n := range counts {
+			st.total += n
+			st.byErrno[name] += n
+		}
+		for _, d := range dirs {
+			stat := &errorDirStat{count: d.Count, errnos: make(map[string]struct{}, len(d.Errnos))}
+			for _, name := range d.Errnos {
+				stat.errnos[name] = struct{}{}
+			}
+			st.dirs[d.Path] = stat
+		}
+	}
+	return nil
+}
+
+// add 记录一个错误（计入报告），返回待提交的错误记录
+func (l *errorLog) add(e *ScanError) *ErrorRecord {
+	name, code := Errno(e.Err)
+	// 目录本身出错时按该目录汇总，子项出错（通常是缺少目录的权限）按所在目录汇总
+	dir := e.Path
+	if !e.IsDir {
+		dir = filepath.Dir(e.Path)
+	}
+	rec := &ErrorRecord{
+		Time:  time.Now().Format(time.RFC3339),
+		Op:    e.Op,
+		Path:  e.Path,
+		Dir:   dir,
+		Errno: name,
+		Code:  code,
+	}
+	if !utf8.ValidString(e.Path) {
+		rec.PathBytes = []byte(e.Path)
+	}
+	if e.Err != nil {
+		rec.Error = e.Err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.live.add(name, dir)
+	return rec
+}
+
+// commit 提交错误：计入检查点的错误统计并写入错误文件，写入失败时返回错误
+func (l *errorLog) commit(recs []*ErrorRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rec := range recs {
+		l.committed.add(rec.Errno, rec.Dir)
+	}
+	if l.file == nil || l.failed {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		if err := writeRecord(&buf, rec); err != nil {
+			return fmt.Errorf("写入错误文件失败: %v", err)
+		}
+	}
+	n, err := l.file.Write(buf.Bytes())
+	l.offset += int64(n)
+	if err != nil {
+		l.failed = true
+		return fmt.Errorf("写入错误文件失败: %v", err)
+	}
+	return nil
+}
+
+// snapshot 已提交的错误统计和错误文件长度（保存检查点时调用），目录按路径排列
+func (l *errorLog) snapshot() (counts map[string]int64, dirs []ErrorDir, offset int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.committed.byErrno) > 0 {
+		counts = make(map[string]int64, len(l.committed.byErrno))
+		for name, n := range l.committed.byErrno {
+			counts[name] = n
+		}
+	}
+	dirs = l.committed.errorDirs()
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+	return counts, dirs, l.offset
+}
+
+// sync 将错误文件落盘（保存检查点之前调用）
+func (l *errorLog) sync() error {
+	if l.file == nil {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("同步错误文件失败: %v", err)
+	}
+	return nil
+}
+
+// errorDirs 各目录的错误数和 errno（未排序）
+func (st *errorStats) errorDirs() []ErrorDir {
+	dirs := make([]ErrorDir, 0, len(st.dirs))
+	for path, d := range st.dirs {
+		dir := ErrorDir{Path: path, Count: d.count}
+		for name := range d.errnos {
+			dir.Errnos = append(dir.Errnos, name)
+		}
+		sort.Strings(dir.Errnos)
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// counts 各 errno 的错误数
+func (l *errorLog) counts() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.live.byErrno) == 0 {
+		return nil
+	}
+	counts := make(map[string]int64, len(l.live.byErrno))
+	for name, n := range l.live.byErrno {
+		counts[name] = n
+	}
+	return counts
+}
+
+// report 按 errno 和目录分组的统计，都按错误数从多到少排列
+func (l *errorLog) report() *ErrorReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := &ErrorReport{Total: l.live.total}
+	for name, n := range l.live.byErrno {
+		report.ByErrno = append(report.ByErrno, ErrnoCount{Errno: name, Count: n})
+	}
+	sort.Slice(report.ByErrno, func(i, j int) bool {
+		a, b := report.ByErrno[i], report.ByErrno[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Errno < b.Errno
+	})
+
+	report.TopDirs = l.live.errorDirs()
+	sort.Slice(report.TopDirs, func(i, j int) bool {
+		a, b := report.TopDirs[i], report.TopDirs[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Path < b.Path
+	})
+	if len(report.TopDirs) > errorTopDirs {
+		report.TopDirs = report.TopDirs[:errorTopDirs]
+	}
+	return report
+}
+
+func (l *errorLog) close() {
+	if l.file != nil {
+		l.file.Close()
+	}
+}
+
+// reportError 报告一个不属于目录批次的错误（如写入输出文件失败），立即提交
+func (s *Scanner) reportError(e *ScanError) {
+	s.commitErrors([]*ErrorRecord{s.logError(e)})
+}
+
+// reportBatchError 报告扫描目录时的错误，随目录批次提交后才写入错误文件并计入检查点的错误统计
+// 恢复扫描时未提交的目录会重新扫描并再次报告，不会重复计数
+func (s *Scanner) reportBatchError(b *dirBatch, e *ScanError) {
+	b.addError(s.logError(e))
+}
+
+// logError 按 errno 计入错误报告并交给 Sink，返回待提交的错误记录
+func (s *Scanner) logError(e *ScanError) *ErrorRecord {
+	rec := s.errors.add(e)
+	s.sink.Error(e)
+	return rec
+}
+
+// commitErrors 提交错误记录
+func (s *Scanner) commitErrors(recs []*ErrorRecord) {
+	if err := s.errors.commit(recs); err != nil {
+		s.sink.Error(err)
+	}
+}
+
+// ErrorReport 返回按 errno 和目录分组的错误统计（扫描结束后调用）
+func (s *Scanner) ErrorReport() *ErrorReport {
+	return s.errors.report()
+}
@@ -0,0 +1,248 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 扫描在遇到 EACCES 之后中断并恢复，错误文件、结束统计和错误报告应描述同一组错误
+func TestResumeErrorsFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root 不受目录权限限制，无法产生 EACCES")
+	}
+
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "tree")
+	var restore []string
+	t.Cleanup(func() {
+		for _, dir := range restore {
+			os.Chmod(dir, 0755)
+		}
+	})
+	mkdir := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chmod := func(path string, mode os.FileMode) {
+		t.Helper()
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		restore = append(restore, path)
+	}
+
+	// 每个目录中有一个无法读取的子目录（各 1 个错误，按该子目录汇总）
+	const lockedDirs = 20
+	for i := 0; i < lockedDirs; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%02d", i))
+		mkdir(filepath.Join(dir, "locked"))
+		writeFile(filepath.Join(dir, "f"))
+		writeFile(filepath.Join(dir, "locked", "f"))
+		chmod(filepath.Join(dir, "locked"), 0)
+	}
+	// 可以列出但无法访问子项的目录（3 个错误，按该目录汇总）
+	noexec := filepath.Join(root, "zz-noexec")
+	mkdir(noexec)
+	for i := 0; i < 3; i++ {
+		writeFile(filepath.Join(noexec, fmt.Sprintf("f%d", i)))
+	}
+	chmod(noexec, 0644)
+	const totalErrors = lockedDirs + 3
+
+	options := Options{
+		RootPath:           root,
+		OutputFile:         filepath.Join(tmp, "scan.jsonl"),
+		ErrorsFile:         filepath.Join(tmp, "errors.jsonl"),
+		CheckpointInterval: time.Hour, // 只在中断时保存检查点
+		WorkerCount:        1,
+	}
+
+	// 第一次扫描：收到第一个 EACCES 后中断
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	var once sync.Once
+	options.Sink = SinkFuncs{OnError: func(err error) {
+		if errors.Is(err, syscall.EACCES) {
+			once.Do(func() { cancel(fmt.Errorf("测试中断")) })
+		}
+	}}
+	s, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Scan(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("第一次扫描应被中断，实际返回 %v", err)
+	}
+	first := readErrorRecords(t, options.ErrorsFile)
+	if len(first) == 0 || len(first) >= totalErrors {
+		t.Fatalf("中断时错误文件中有 %d 条记录，应在 1 到 %d 之间", len(first), totalErrors-1)
+	}
+
+	// 模拟检查点之后写入、尚未确认的记录，恢复时应被截掉
+	f, err := os.OpenFile(options.ErrorsFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, `{"op":"检查点之后","path":"/stale","dir":"/stale","errno":"EIO"}`)
+	f.Close()
+
+	// 恢复扫描
+	options.Resume = true
+	options.Sink = nil
+	s, err = New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("恢复扫描失败: %v", err)
+	}
+
+	records := readErrorRecords(t, options.ErrorsFile)
+	byErrno := make(map[string]int64)
+	byDir := make(map[string]int64)
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if seen[rec.Path] {
+			t.Errorf("错误文件中 %s 重复出现", rec.Path)
+		}
+		seen[rec.Path] = true
+		byErrno[rec.Errno]++
+		byDir[rec.Dir]++
+	}
+	for _, rec := range first {
+		if !seen[rec.Path] {
+			t.Errorf("中断之前的错误 %s 不在错误文件中", rec.Path)
+		}
+	}
+
+	if len(records) != totalErrors {
+		t.Errorf("错误文件中有 %d 条记录，应为 %d", len(records), totalErrors)
+	}
+	if byErrno["EACCES"] != totalErrors || len(byErrno) != 1 {
+		t.Errorf("错误文件的 errno 分类 = %v，应全部为 EACCES", byErrno)
+	}
+	if stats.Errors != int64(len(records)) {
+		t.Errorf("统计的错误数 %d 与错误文件的 %d 条记录不一致", stats.Errors, len(records))
+	}
+	if !equalCounts(stats.ErrorsByErrno, byErrno) {
+		t.Errorf("统计的 errno 分类 %v 与错误文件 %v 不一致", stats.ErrorsByErrno, byErrno)
+	}
+
+	report := s.ErrorReport()
+	if report.Total != int64(len(records)) {
+		t.Errorf("错误报告的总数 %d 与错误文件的 %d 条记录不一致", report.Total, len(records))
+	}
+	reportErrnos := make(map[string]int64)
+	for _, c := range report.ByErrno {
+		reportErrnos[c.Errno] = c.Count
+	}
+	if !equalCounts(reportErrnos, byErrno) {
+		t.Errorf("错误报告的 errno 分类 %v 与错误文件 %v 不一致", reportErrnos, byErrno)
+	}
+	if len(report.TopDirs) != errorTopDirs {
+		t.Fatalf("错误最多的目录有 %d 个，应为 %d", len(report.TopDirs), errorTopDirs)
+	}
+	if report.TopDirs[0].Path != noexec || report.TopDirs[0].Count != 3 {
+		t.Errorf("错误最多的目录 = %s (%d)，应为 %s (3)", report.TopDirs[0].Path, report.TopDirs[0].Count, noexec)
+	}
+	for _, d := range report.TopDirs {
+		if byDir[d.Path] != d.Count {
+			t.Errorf("错误报告中 %s 有 %d 个错误，错误文件中有 %d 个", d.Path, d.Count, byDir[d.Path])
+		}
+	}
+}
+
+// 恢复扫描时错误文件需要与中断的扫描相同
+func TestResumeErrorsFileMismatch(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "tree")
+	for i := 0; i < 50; i++ {
+		if err := os.MkdirAll(filepath.Join(root, fmt.Sprintf("d%02d", i)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := Options{
+		RootPath:           root,
+		OutputFile:         filepath.Join(tmp, "scan.jsonl"),
+		ErrorsFile:         filepath.Join(tmp, "errors.jsonl"),
+		CheckpointInterval: time.Hour,
+		WorkerCount:        1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	options.Sink = SinkFuncs{OnEntry: func(node *FileNode) { cancel() }}
+	s, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Scan(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("扫描应被中断，实际返回 %v", err)
+	}
+
+	options.Resume = true
+	options.ErrorsFile = filepath.Join(tmp, "other.jsonl")
+	s, err = New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Scan(context.Background()); err == nil {
+		t.Fatal("错误文件与检查点不一致时恢复扫描应返回错误")
+	}
+	if _, err := os.Stat(options.ErrorsFile); !os.IsNotExist(err) {
+		t.Error("恢复失败时不应创建新的错误文件")
+	}
+}
+
+func readErrorRecords(t *testing.T, path string) []ErrorRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []ErrorRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec ErrorRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("错误文件中的记录无法解析: %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func equalCounts(a, b map[string]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
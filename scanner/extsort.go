@@ -0,0 +1,464 @@
+package scanner
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// 每个排序块最多容纳的记录数，超过后写入临时文件
+const extSortChunkLines = 200000
+
+// SortedLine 一条带排序键的原始记录
+type SortedLine struct {
+	Path string
+	Type string // 记录类型，普通文件/目录记录为空
+	Line []byte
+}
+
+// LineIterator 按路径顺序迭代记录
+type LineIterator interface {
+	Next() (SortedLine, bool, error) // 没有更多记录时返回 false
+	Close()
+}
+
+// extSorter 按路径对 JSON Lines 记录做外部排序，内存占用与单个排序块大小相关
+// 原始行保持不变，只解析 path 作为排序键
+type extSorter struct {
+	buf    []SortedLine
+	chunks []string // 已写出的有序临时文件
+}
+
+// add 添加一条记录（line 会被复制）
+func (e *extSorter) add(l SortedLine) error {
+	l.Line = append([]byte(nil), l.Line...)
+	e.buf = append(e.buf, l)
+	if len(e.buf) >= extSortChunkLines {
+		return e.spill()
+	}
+	return nil
+}
+
+// spill 将当前缓冲排序后写入临时文件
+func (e *extSorter) spill() error {
+	e.sortBuf()
+
+	f, err := os.CreateTemp("", "mac-file-search-sort-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("无法创建排序临时文件: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, l := range e.buf {
+		w.Write(l.Line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("写入排序临时文件失败: %v", err)
+	}
+	f.Close()
+
+	e.chunks = append(e.chunks, f.Name())
+	e.buf = e.buf[:0]
+	return nil
+}
+
+func (e *extSorter) sortBuf() {
+	sort.Slice(e.buf, func(i, j int) bool { return e.buf[i].less(e.buf[j]) })
+}
+
+// less 按路径排序，同一路径的文件/目录记录排在目录汇总等带 type 的记录之前
+func (l SortedLine) less(other SortedLine) bool {
+	if l.Path != other.Path {
+		return l.Path < other.Path
+	}
+	return l.Type < other.Type
+}
+
+// finish 结束添加，返回有序迭代器；没有溢出到临时文件时直接在内存中迭代
+func (e *extSorter) finish() (LineIterator, error) {
+	if len(e.chunks) == 0 {
+		e.sortBuf()
+		return &sliceIterator{lines: e.buf}, nil
+	}
+	if len(e.buf) > 0 {
+		if err := e.spill(); err != nil {
+			e.cleanup()
+			return nil, err
+		}
+	}
+
+	m := &mergeIterator{files: e.chunks}
+	for _, chunk := range e.chunks {
+		f, err := os.Open(chunk)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("无法打开排序临时文件: %v", err)
+		}
+		c := &chunkReader{file: f, scanner: bufio.NewScanner(f)}
+		c.scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		m.readers = append(m.readers, c)
+		if err := c.advance(); err != nil {
+			m.Close()
+			return nil, err
+		}
+		if c.ok {
+			heap.Push(&m.heap, c)
+		}
+	}
+	return m, nil
+}
+
+// cleanup 删除临时文件
+func (e *extSorter) cleanup() {
+	for _, chunk := range e.chunks {
+		os.Remove(chunk)
+	}
+}
+
+// sliceIterator 内存中的有序记录
+type sliceIterator struct {
+	lines []SortedLine
+	pos   int
+}
+
+func (it *sliceIterator) Next() (SortedLine, bool, error) {
+	if it.pos >= len(it.lines) {
+		return SortedLine{}, false, nil
+	}
+	l := it.lines[it.pos]
+	it.pos++
+	return l, true, nil
+}
+
+func (it *sliceIterator) Close() {}
+
+// chunkReader 读取一个有序临时文件
+type chunkReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	current SortedLine
+	ok      bool
+}
+
+func (c *chunkReader) advance() error {
+	if !c.scanner.Scan() {
+		c.ok = false
+		return c.scanner.Err()
+	}
+	line := append([]byte(nil), c.scanner.Bytes()...)
+	path, recType, err := recordKey(line)
+	if err != nil {
+		return err
+	}
+	c.current = SortedLine{Path: path, Type: recType, Line: line}
+	c.ok = true
+	return nil
+}
+
+// chunkHeap 按当前记录路径排序的最小堆
+type chunkHeap []*chunkReader
+
+func (h chunkHeap) Len() int            { return len(h) }
+func (h chunkHeap) Less(i, j int) bool  { return h[i].current.less(h[j].current) }
+func (h chunkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *chunkHeap) Push(x interface{}) { *h = append(*h, x.(*chunkReader)) }
+func (h *chunkHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// mergeIterator 多路归并多个有序临时文件
+type mergeIterator struct {
+	files   []string
+	readers []*chunkReader
+	heap    chunkHeap
+}
+
+func (m *mergeIterator) Next() (SortedLine, bool, error) {
+	if len(m.heap) == 0 {
+		return SortedLine{}, false, nil
+	}
+	c := m.heap[0]
+	l := c.current
+	if err := c.advance(); err != nil {
+		return SortedLine{}, false, err
+	}
+	if c.ok {
+		heap.Fix(&m.heap, 0)
+	} else {
+		heap.Pop(&m.heap)
+	}
+	return l, true, nil
+}
+
+func (m *mergeIterator) Close() {
+	for _, c := range m.readers {
+		c.file.Close()
+	}
+	for _, f := range m.files {
+		os.Remove(f)
+	}
+}
+
+// recordKey 从一行记录中解析出路径（还原非 UTF-8 的路径）和记录类型
+func recordKey(line []byte) (path, recType string, err error) {
+	var rec struct {
+		Type      string `json:"type"`
+		Path      string `json:"path"`
+		PathBytes []byte `json:"path_bytes"`
+	}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return "", "", err
+	}
+	if rec.PathBytes != nil {
+		return string(rec.PathBytes), rec.Type, nil
+	}
+	return rec.Path, rec.Type, nil
+}
+
+// isRecordLine 判断一行是否为数据记录（跳过注释行和空行）
+func isRecordLine(line []byte) bool {
+	for _, c := range line {
+		switch c {
+		case ' ', '\t', '\r':
+			continue
+		case '#':
+			return false
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// isHeaderLine 判断一行是否为文件头记录
+func isHeaderLine(line []byte) bool {
+	_, recType, err := recordKey(line)
+	return err == nil && recType == recordTypeHeader
+}
+
+// SortedScanLines 按路径顺序读取扫描结果文件中的文件/目录记录（跳过目录汇总等带 type 的记录）
+// 文件本身已经有序时（例如使用 -sorted 输出）直接流式读取，否则先做外部排序
+func SortedScanLines(path string) (LineIterator, error) {
+	sorted, err := isScanFileSorted(path)
+	if err != nil {
+		return nil, err
+	}
+	if sorted {
+		it, err := newFileLineIterator(path)
+		if err != nil {
+			return nil, err
+		}
+		return &dataLineIterator{it}, nil
+	}
+
+	var sorter extSorter
+	err = forEachScanLine(path, func(l SortedLine) error {
+		if l.Type != "" {
+			return nil
+		}
+		return sorter.add(l)
+	})
+	if err != nil {
+		sorter.cleanup()
+		return nil, err
+	}
+	return sorter.finish()
+}
+
+// dataLineIterator 跳过带 type 的记录，只返回文件/目录记录
+type dataLineIterator struct {
+	LineIterator
+}
+
+func (it *dataLineIterator) Next() (SortedLine, bool, error) {
+	for {
+		l, ok, err := it.LineIterator.Next()
+		if err != nil || !ok || l.Type == "" {
+			return l, ok, err
+		}
+	}
+}
+
+// isScanFileSorted 检查扫描结果文件中的文件/目录记录是否已按路径排序
+func isScanFileSorted(path string) (bool, error) {
+	var last string
+	sorted := true
+	errStop := fmt.Errorf("unsorted")
+	err := forEachScanLine(path, func(l SortedLine) error {
+		if l.Type != "" {
+			return nil
+		}
+		if l.Path < last {
+			sorted = false
+			return errStop
+		}
+		last = l.Path
+		return nil
+	})
+	if err != nil && err != errStop {
+		return false, err
+	}
+	return sorted, nil
+}
+
+// forEachScanLine 逐行读取扫描结果文件中的记录及其路径，无法解析的行会被跳过
+// 传给 fn 的 line 在下一次调用时会被覆盖，需要保留时由调用方复制
+func forEachScanLine(path string, fn func(l SortedLine) error) error {
+	f, err := OpenScanFile(path)
+	if err != nil {
+		return fmt.Errorf("无法打开扫描结果文件: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !isRecordLine(line) {
+			continue
+		}
+		p, recType, err := recordKey(line)
+		if err != nil {
+			continue
+		}
+		if err := fn(SortedLine{Path: p, Type: recType, Line: line}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取扫描结果文件失败: %v", err)
+	}
+	return nil
+}
+
+// fileLineIterator 流式读取已经有序的扫描结果文件
+type fileLineIterator struct {
+	file    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+func newFileLineIterator(path string) (*fileLineIterator, error) {
+	f, err := OpenScanFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开扫描结果文件: %v", err)
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	return &fileLineIterator{file: f, scanner: scanner}, nil
+}
+
+func (it *fileLineIterator) Next() (SortedLine, bool, error) {
+	for it.scanner.Scan() {
+		line := it.scanner.Bytes()
+		if !isRecordLine(line) {
+			continue
+		}
+		p, recType, err := recordKey(line)
+		if err != nil {
+			continue
+		}
+		return SortedLine{Path: p, Type: recType, Line: append([]byte(nil), line...)}, true, nil
+	}
+	return SortedLine{}, false, it.scanner.Err()
+}
+
+func (it *fileLineIterator) Close() {
+	it.file.Close()
+}
+
+// sortOutputFile 将扫描结果文件按路径排序（原地替换），文件头保留在最前面，压缩的输出文件排序后仍按原格式压缩
+func sortOutputFile(path string) error {
+	f, err := OpenScanFile(path)
+	if err != nil {
+		return fmt.Errorf("无法打开输出文件: %v", err)
+	}
+
+	// 复制文件头（开头的文件头记录，以及旧版本的注释行和空行）
+	var header []byte
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && (!isRecordLine(line) || isHeaderLine(line)) {
+			header = append(header, line...)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			f.Close()
+			return fmt.Errorf("读取输出文件失败: %v", err)
+		}
+		break
+	}
+	f.Close()
+
+	var sorter extSorter
+	err = forEachScanLine(path, func(l SortedLine) error {
+		if l.Type == recordTypeHeader || l.Type == recordTypeSummary {
+			return nil
+		}
+		return sorter.add(l)
+	})
+	if err != nil {
+		sorter.cleanup()
+		return err
+	}
+	it, err := sorter.finish()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	tmpPath := path + ".sorting"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("无法创建排序文件: %v", err)
+	}
+	cw, err := newCompressWriter(out, compressionFor(path))
+	if err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("无法创建排序文件: %v", err)
+	}
+	var w *bufio.Writer
+	if cw != nil {
+		w = bufio.NewWriter(cw)
+	} else {
+		w = bufio.NewWriter(out)
+	}
+	w.Write(header)
+	for {
+		l, ok, err := it.Next()
+		if err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("排序失败: %v", err)
+		}
+		if !ok {
+			break
+		}
+		w.Write(l.Line)
+		w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if err == nil && cw != nil {
+		err = cw.Close()
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入排序文件失败: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入排序文件失败: %v", err)
+	}
+	return os.Rename(tmpPath, path)
+}
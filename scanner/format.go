@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatSize 格式化文件大小
+func FormatSize(size int64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+		TB = GB * 1024
+	)
+
+	switch {
+	case size >= TB:
+		return fmt.Sprintf("%.2f TB", float64(size)/TB)
+	case size >= GB:
+		return fmt.Sprintf("%.2f GB", float64(size)/GB)
+	case size >= MB:
+		return fmt.Sprintf("%.2f MB", float64(size)/MB)
+	case size >= KB:
+		return fmt.Sprintf("%.2f KB", float64(size)/KB)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// FormatSpeed 格式化速度
+func FormatSpeed(bytesPerSec float64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+	)
+
+	switch {
+	case bytesPerSec >= GB:
+		return fmt.Sprintf("%.1f GB", bytesPerSec/GB)
+	case bytesPerSec >= MB:
+		return fmt.Sprintf("%.1f MB", bytesPerSec/MB)
+	case bytesPerSec >= KB:
+		return fmt.Sprintf("%.1f KB", bytesPerSec/KB)
+	default:
+		return fmt.Sprintf("%.0f B", bytesPerSec)
+	}
+}
+
+// FormatNumber 格式化数字（添加千位分隔符）
+func FormatNumber(n int64) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	if n < 1000000 {
+		return fmt.Sprintf("%d,%03d", n/1000, n%1000)
+	}
+	return fmt.Sprintf("%d,%03d,%03d", n/1000000, (n/1000)%1000, n%1000)
+}
+
+// ParseSize 解析人性化的文件大小字符串 (支持 K/M/G/T 后缀)
+// 例如: "100M" -> 104857600, "1.5G" -> 1610612736
+func ParseSize(sizeStr string) (int64, error) {
+	if sizeStr == "" || sizeStr == "0" {
+		return 0, nil
+	}
+
+	// 去除空格
+	sizeStr = strings.TrimSpace(sizeStr)
+
+	// 转换为大写以支持大小写
+	upper := strings.ToUpper(sizeStr)
+
+	// 定义单位
+	multipliers := map[string]int64{
+		"K": 1024,
+		"M": 1024 * 1024,
+		"G": 1024 * 1024 * 1024,
+		"T": 1024 * 1024 * 1024 * 1024,
+	}
+
+	// 检查是否有单位后缀
+	for suffix, multiplier := range multipliers {
+		if strings.HasSuffix(upper, suffix) {
+			// 去除后缀，解析数字
+			numStr := strings.TrimSuffix(upper, suffix)
+			numStr = strings.TrimSpace(numStr)
+
+			// 解析数字（支持小数）
+			num, err := strconv.ParseFloat(numStr, 64)
+			if err != nil {
+				return 0, fmt.Errorf("无效的数字: %s", numStr)
+			}
+
+			return int64(num * float64(multiplier)), nil
+		}
+	}
+
+	// 没有单位后缀，直接解析为字节数
+	num, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的大小格式: %s (支持格式: 100M, 1.5G, 1024)", sizeStr)
+	}
+
+	return num, nil
+}
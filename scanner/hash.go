@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	hashSampleChunk = 1024 * 1024 // 抽样哈希时每段读取的字节数
+	hashBufferSize  = 128 * 1024  // 每个哈希 worker 的读缓冲
+	hashQueueFactor = 64          // 哈希队列长度 = worker 数 * hashQueueFactor
+)
+
+// 超过大小上限的文件的处理方式
+const (
+	HashLargeSkip   = "skip"   // 不计算哈希
+	HashLargeSample = "sample" // 抽样计算（文件大小 + 头/中/尾各 1MB）
+)
+
+// newHasher 根据算法名称创建哈希函数
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New(), nil
+	case "xxhash":
+		return xxhash.New(), nil
+	case "blake":
+		return blake2b.New256(nil)
+	default:
+		return nil, fmt.Errorf("不支持的哈希算法: %s (支持: sha256, xxhash, blake)", algo)
+	}
+}
+
+// hashPrefix 返回写入记录的哈希前缀，便于区分不同算法的结果
+func hashPrefix(algo string) string {
+	if algo == "blake" {
+		return "blake2b:"
+	}
+	return algo + ":"
+}
+
+// hashJob 一个待计算哈希的文件
+type hashJob struct {
+	node  *FileNode
+	batch *dirBatch
+}
+
+// hashPool 独立的有界哈希 worker 池
+// 目录遍历只负责提交任务，读取文件内容在这里完成，避免大文件拖慢遍历；
+// 队列满时提交会阻塞，从而限制等待哈希的文件数量
+type hashPool struct {
+	scanner *Scanner
+	jobs    chan hashJob
+	wg      sync.WaitGroup
+}
+
+func newHashPool(s *Scanner, workers int) *hashPool {
+	p := &hashPool{
+		scanner: s,
+		jobs:    make(chan hashJob, workers*hashQueueFactor),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// submit 提交哈希任务，任务完成前目录的提交会被推迟
+func (p *hashPool) submit(b *dirBatch, node *FileNode) {
+	b.pending.Add(1)
+	p.jobs <- hashJob{node: node, batch: b}
+}
+
+// close 等待所有任务完成后退出
+func (p *hashPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+func (p *hashPool) worker() {
+	defer p.wg.Done()
+
+	opts := &p.scanner.options
+	buf := make([]byte, hashBufferSize) // 复用读缓冲，避免每个文件分配一次
+	for job := range p.jobs {
+		sum, sampled, err := hashFileContent(job.node.Path, job.node.Size, opts.HashAlgo, opts.HashMaxSize, opts.HashLarge, buf)
+		if err != nil {
+			job.batch.hashErrors.Add(1)
+			p.scanner.reportBatchError(job.batch, &ScanError{Op: "无法计算哈希", Path: job.node.Path, Err: err})
+		} else if sum != "" {
+			job.node.Hash = sum
+			job.node.HashSampled = sampled
+		}
+		p.scanner.releaseBatch(job.batch)
+	}
+}
+
+// hashFileContent 计算文件内容哈希
+// 超过 maxSize 的文件按 large 的设置跳过（返回空字符串）或抽样计算
+func hashFileContent(path string, size int64, algo string, maxSize int64, large string, buf []byte) (sum string, sampled bool, err error) {
+	if maxSize > 0 && size > maxSize && large != HashLargeSample {
+		return "", false, nil
+	}
+
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	// 只暴露 Read，确保 io.CopyBuffer 使用传入的缓冲而不是 os.File.WriteTo 的内部分配
+	r := struct{ io.Reader }{f}
+
+	if maxSize > 0 && size > maxSize && size > 3*hashSampleChunk {
+		// 抽样：文件大小 + 头、中、尾各一段，大小不同的文件不会得到相同结果
+		var sizeBuf [8]byte
+		binary.LittleEndian.PutUint64(sizeBuf[:], uint64(size))
+		h.Write(sizeBuf[:])
+		for _, offset := range []int64{0, size/2 - hashSampleChunk/2, size - hashSampleChunk} {
+			if _, err := io.CopyBuffer(h, io.NewSectionReader(f, offset, hashSampleChunk), buf); err != nil {
+				return "", false, err
+			}
+		}
+		sampled = true
+	} else if _, err := io.CopyBuffer(h, r, buf); err != nil {
+		return "", false, err
+	}
+
+	return hashPrefix(algo) + hex.EncodeToString(h.Sum(nil)), sampled, nil
+}
@@ -0,0 +1,207 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 扫描过程中遵循的忽略文件，同一目录中靠后的文件优先级更高
+var ignoreFileNames = []string{".gitignore", ".ignore", ".mfsignore"}
+
+// ignorePattern 一条 gitignore 语法的规则
+type ignorePattern struct {
+	base     string   // 规则的基准目录（忽略文件所在目录；-exclude-glob 为扫描根目录）
+	negate   bool     // ! 开头：重新包含
+	dirOnly  bool     // / 结尾：只匹配目录
+	anchored bool     // 含有 /：相对基准目录匹配完整路径；否则匹配任意层级的名称
+	segments []string // 按 / 分割的模式，** 匹配任意多层目录
+}
+
+// ignoreRules 一个目录生效的忽略规则，parent 为上级目录的规则（越深的目录优先级越高）
+type ignoreRules struct {
+	parent   *ignoreRules
+	patterns []ignorePattern
+}
+
+// parseIgnorePattern 解析一行规则，空行和注释返回 ok=false
+func parseIgnorePattern(base, line string) (p ignorePattern, ok bool, err error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+
+	p.base = base
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		p.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return p, false, nil
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+	}
+
+	p.segments = strings.Split(line, "/")
+	for _, seg := range p.segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return p, false, fmt.Errorf("无效的匹配模式 %q: %v", line, err)
+		}
+	}
+	return p, true, nil
+}
+
+// newIgnoreRules 从模式列表创建规则（用于 -exclude-glob）
+func newIgnoreRules(base string, patterns []string) (*ignoreRules, error) {
+	rules := &ignoreRules{}
+	for _, line := range patterns {
+		p, ok, err := parseIgnorePattern(base, line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules.patterns = append(rules.patterns, p)
+		}
+	}
+	if len(rules.patterns) == 0 {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// match 判断路径是否被忽略：按从上级到下级、从前到后的顺序，最后一条匹配的规则生效
+func (r *ignoreRules) match(fullPath string, isDir bool) bool {
+	ignored, _ := r.lastMatch(fullPath, isDir)
+	return ignored
+}
+
+func (r *ignoreRules) lastMatch(fullPath string, isDir bool) (ignored, matched bool) {
+	if r == nil {
+		return false, false
+	}
+	ignored, matched = r.parent.lastMatch(fullPath, isDir)
+	for i := range r.patterns {
+		if r.patterns[i].match(fullPath, isDir) {
+			ignored, matched = !r.patterns[i].negate, true
+		}
+	}
+	return ignored, matched
+}
+
+// match 判断单条规则是否匹配
+func (p *ignorePattern) match(fullPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], filepath.Base(fullPath))
+		return ok
+	}
+	if fullPath == p.base || !IsPathWithin(fullPath, p.base) {
+		return false
+	}
+	rel := strings.TrimPrefix(fullPath[len(p.base):], "/")
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments 逐段匹配，** 匹配零个或多个目录
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// 结尾的 ** 匹配目录下的所有内容（不含目录本身）
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// loadIgnoreRules 读取目录中的忽略文件，返回该目录生效的规则（没有忽略文件时返回 parent）
+// entries 为目录的子项，用于判断忽略文件是否存在以省去无效的 open；为 nil 时直接尝试读取
+// 规则有误时报告到目录批次 b；b 为 nil 时（恢复扫描重建上级目录的规则）不再报告，这些错误在之前的扫描中已经计入
+func (s *Scanner) loadIgnoreRules(b *dirBatch, dirPath string, parent *ignoreRules, entries []os.DirEntry) *ignoreRules {
+	var names []string
+	if entries == nil {
+		names = ignoreFileNames
+	} else {
+		for _, entry := range entries {
+			for _, name := range ignoreFileNames {
+				if entry.Name() == name && !entry.IsDir() {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	var rules *ignoreRules
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dirPath, name))
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			p, ok, err := parseIgnorePattern(dirPath, string(line))
+			if err != nil {
+				if b != nil {
+					s.reportBatchError(b, &ScanError{Op: "忽略文件中的规则有误", Path: filepath.Join(dirPath, name), Err: err})
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if rules == nil {
+				rules = &ignoreRules{parent: parent}
+			}
+			rules.patterns = append(rules.patterns, p)
+		}
+	}
+	if rules == nil {
+		return parent
+	}
+	return rules
+}
+
+// ancestorIgnoreRules 从扫描根目录开始逐级读取忽略文件，返回 dirPath 上级目录生效的规则
+// 恢复扫描时待扫描目录的上级目录不会重新扫描，用这种方式重建规则；cache 在多个目录间复用
+func (s *Scanner) ancestorIgnoreRules(dirPath string, cache map[string]*ignoreRules) *ignoreRules {
+	parentPath := filepath.Dir(dirPath)
+	root := s.rootOf(dirPath).path
+	if dirPath == root || parentPath == dirPath || !IsPathWithin(parentPath, root) {
+		return nil
+	}
+	if rules, ok := cache[parentPath]; ok {
+		return rules
+	}
+	rules := s.loadIgnoreRules(nil, parentPath, s.ancestorIgnoreRules(parentPath, cache), nil)
+	cache[parentPath] = rules
+	return rules
+}
@@ -0,0 +1,259 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mustRules 以 base 为基准目录解析规则，parent 为上级目录的规则
+func mustRules(t *testing.T, parent *ignoreRules, base string, lines ...string) *ignoreRules {
+	t.Helper()
+	rules := &ignoreRules{parent: parent}
+	for _, line := range lines {
+		p, ok, err := parseIgnorePattern(base, line)
+		if err != nil {
+			t.Fatalf("解析规则 %q 失败: %v", line, err)
+		}
+		if ok {
+			rules.patterns = append(rules.patterns, p)
+		}
+	}
+	return rules
+}
+
+func TestParseIgnorePattern(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		negate   bool
+		dirOnly  bool
+		anchored bool
+		segments []string
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# 注释", ok: false},
+		{line: "/", ok: false},
+		{line: "!", ok: false},
+		{line: "*.log", ok: true, segments: []string{"*.log"}},
+		{line: "*.log \t\r", ok: true, segments: []string{"*.log"}},
+		{line: "!keep.log", ok: true, negate: true, segments: []string{"keep.log"}},
+		{line: `\!important`, ok: true, segments: []string{"!important"}},
+		{line: `\#file`, ok: true, segments: []string{"#file"}},
+		{line: "build/", ok: true, dirOnly: true, segments: []string{"build"}},
+		{line: "/build", ok: true, anchored: true, segments: []string{"build"}},
+		{line: "/build/", ok: true, dirOnly: true, anchored: true, segments: []string{"build"}},
+		{line: "docs/*.pdf", ok: true, anchored: true, segments: []string{"docs", "*.pdf"}},
+		{line: "**/node_modules", ok: true, anchored: true, segments: []string{"**", "node_modules"}},
+		{line: "!/dist/", ok: true, negate: true, dirOnly: true, anchored: true, segments: []string{"dist"}},
+	}
+	for _, tt := range tests {
+		p, ok, err := parseIgnorePattern("/r", tt.line)
+		if err != nil {
+			t.Errorf("%q: 意外的错误: %v", tt.line, err)
+			continue
+		}
+		if ok != tt.ok {
+			t.Errorf("%q: ok = %v，应为 %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if p.negate != tt.negate || p.dirOnly != tt.dirOnly || p.anchored != tt.anchored {
+			t.Errorf("%q: negate/dirOnly/anchored = %v/%v/%v，应为 %v/%v/%v",
+				tt.line, p.negate, p.dirOnly, p.anchored, tt.negate, tt.dirOnly, tt.anchored)
+		}
+		if len(p.segments) != len(tt.segments) {
+			t.Errorf("%q: segments = %q，应为 %q", tt.line, p.segments, tt.segments)
+			continue
+		}
+		for i := range p.segments {
+			if p.segments[i] != tt.segments[i] {
+				t.Errorf("%q: segments = %q，应为 %q", tt.line, p.segments, tt.segments)
+				break
+			}
+		}
+	}
+}
+
+func TestParseIgnorePatternInvalid(t *testing.T) {
+	for _, line := range []string{"[", "a/[b", "foo[!"} {
+		if _, _, err := parseIgnorePattern("/r", line); err == nil {
+			t.Errorf("%q: 应返回错误", line)
+		}
+	}
+}
+
+func TestIgnoreMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		// 不含 / 的模式匹配任意层级的名称
+		{"名称-根目录下", []string{"*.log"}, "/r/a.log", false, true},
+		{"名称-深层", []string{"*.log"}, "/r/x/y/b.log", false, true},
+		{"名称-不匹配", []string{"*.log"}, "/r/a.txt", false, false},
+		{"名称-匹配目录", []string{"cache"}, "/r/x/cache", true, true},
+		{"名称-问号", []string{"?.tmp"}, "/r/a.tmp", false, true},
+		{"名称-问号不匹配多个字符", []string{"?.tmp"}, "/r/ab.tmp", false, false},
+		{"名称-字符集", []string{"[ab].c"}, "/r/b.c", false, true},
+		{"名称-转义的感叹号", []string{`\!important`}, "/r/!important", false, true},
+		{"名称-转义的井号", []string{`\#file`}, "/r/#file", false, true},
+
+		// / 结尾只匹配目录
+		{"目录-匹配目录", []string{"build/"}, "/r/src/build", true, true},
+		{"目录-不匹配文件", []string{"build/"}, "/r/src/build", false, false},
+
+		// / 开头或含有 / 的模式相对基准目录匹配
+		{"锚定-根目录下", []string{"/build"}, "/r/build", true, true},
+		{"锚定-不匹配深层", []string{"/build"}, "/r/src/build", true, false},
+		{"锚定-中间的斜杠", []string{"docs/*.pdf"}, "/r/docs/a.pdf", false, true},
+		{"锚定-中间的斜杠不匹配深层", []string{"docs/*.pdf"}, "/r/x/docs/a.pdf", false, false},
+		{"锚定-星号不跨目录", []string{"docs/*.pdf"}, "/r/docs/sub/a.pdf", false, false},
+		{"锚定-基准目录之外", []string{"/build"}, "/other/build", true, false},
+		{"锚定-前缀相同的目录", []string{"/build"}, "/r2/build", true, false},
+		{"锚定-不匹配基准目录本身", []string{"/**"}, "/r", true, false},
+
+		// ** 匹配任意多层目录
+		{"双星-开头匹配零层", []string{"**/node_modules"}, "/r/node_modules", true, true},
+		{"双星-开头匹配多层", []string{"**/node_modules"}, "/r/a/b/node_modules", true, true},
+		{"双星-中间匹配零层", []string{"a/**/b"}, "/r/a/b", true, true},
+		{"双星-中间匹配多层", []string{"a/**/b"}, "/r/a/x/y/b", true, true},
+		{"双星-中间不匹配其他开头", []string{"a/**/b"}, "/r/c/x/b", true, false},
+		{"双星-结尾匹配内容", []string{"logs/**"}, "/r/logs/x/y.txt", false, true},
+		{"双星-结尾不匹配目录本身", []string{"logs/**"}, "/r/logs", true, false},
+		{"双星-两端", []string{"**/.git/objects"}, "/r/a/.git/objects", true, true},
+
+		// 最后一条匹配的规则生效
+		{"取反-重新包含", []string{"*.log", "!keep.log"}, "/r/keep.log", false, false},
+		{"取反-其他文件仍然忽略", []string{"*.log", "!keep.log"}, "/r/x.log", false, true},
+		{"取反-顺序相反时忽略", []string{"!keep.log", "*.log"}, "/r/keep.log", false, true},
+		{"取反-只有取反规则", []string{"!keep.log"}, "/r/keep.log", false, false},
+		{"取反-只对目录取反", []string{"tmp*", "!tmp/"}, "/r/tmp", true, false},
+		{"取反-只对目录取反不影响文件", []string{"tmp*", "!tmp/"}, "/r/tmp", false, true},
+
+		{"注释和空行", []string{"# *.log", "", "   "}, "/r/a.log", false, false},
+	}
+	for _, tt := range tests {
+		rules := mustRules(t, nil, "/r", tt.lines...)
+		if got := rules.match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("%s: %q 匹配 %s (isDir=%v) = %v，应为 %v", tt.name, tt.lines, tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestIgnoreRulesScoping(t *testing.T) {
+	// /r/.gitignore 与 /r/sub/.gitignore：下级目录的规则优先，锚定的规则只相对所在目录
+	root := mustRules(t, nil, "/r", "*.tmp", "/top-only", "secret")
+	sub := mustRules(t, root, "/r/sub", "!keep.tmp", "/local", "!secret")
+
+	tests := []struct {
+		rules   *ignoreRules
+		path    string
+		ignored bool
+	}{
+		{root, "/r/a.tmp", true},
+		{root, "/r/keep.tmp", true},
+		{sub, "/r/sub/keep.tmp", false},
+		{sub, "/r/sub/deeper/keep.tmp", false},
+		{sub, "/r/sub/other.tmp", true},
+		{root, "/r/top-only", true},
+		{sub, "/r/sub/top-only", false},
+		{sub, "/r/sub/local", true},
+		{root, "/r/local", false},
+		{sub, "/r/sub/deeper/local", false},
+		{root, "/r/secret", true},
+		{sub, "/r/sub/secret", false},
+		{(*ignoreRules)(nil), "/r/a.tmp", false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.match(tt.path, false); got != tt.ignored {
+			t.Errorf("%s: 忽略 = %v，应为 %v", tt.path, got, tt.ignored)
+		}
+	}
+}
+
+func TestNewIgnoreRules(t *testing.T) {
+	rules, err := newIgnoreRules("/r", []string{"", "# 注释"})
+	if err != nil || rules != nil {
+		t.Fatalf("只有空行和注释时应返回 nil, nil，实际为 %v, %v", rules, err)
+	}
+	if _, err := newIgnoreRules("/r", []string{"ok", "["}); err == nil {
+		t.Fatal("无效的模式应返回错误")
+	}
+	rules, err = newIgnoreRules("/r", []string{"**/node_modules", "*.photoslibrary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rules.match("/r/a/node_modules", true) || !rules.match("/r/Photos.photoslibrary", true) || rules.match("/r/src", true) {
+		t.Error("-exclude-glob 规则匹配结果不正确")
+	}
+}
+
+func TestLoadIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore": "*.dat\n# 注释\n\nbuild/\n",
+		".ignore":    "*.bak\n",
+		".mfsignore": "!a.dat\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Scanner{}
+	parent := mustRules(t, nil, filepath.Dir(dir), "*.log")
+	for _, withEntries := range []bool{true, false} {
+		var list []os.DirEntry
+		if withEntries {
+			list = entries
+		}
+		rules := s.loadIgnoreRules(nil, dir, parent, list)
+		tests := []struct {
+			name    string
+			isDir   bool
+			ignored bool
+		}{
+			{"b.dat", false, true},
+			{"a.dat", false, false}, // .mfsignore 在 .gitignore 之后，优先级更高
+			{"x.bak", false, true},
+			{"x.log", false, true}, // 上级目录的规则
+			{"build", true, true},
+			{"build", false, false},
+			{"x.txt", false, false},
+		}
+		for _, tt := range tests {
+			if got := rules.match(filepath.Join(dir, tt.name), tt.isDir); got != tt.ignored {
+				t.Errorf("entries=%v %s (isDir=%v): 忽略 = %v，应为 %v", withEntries, tt.name, tt.isDir, got, tt.ignored)
+			}
+		}
+	}
+
+	// 没有忽略文件时返回上级目录的规则
+	empty := t.TempDir()
+	if rules := s.loadIgnoreRules(nil, empty, parent, nil); rules != parent {
+		t.Error("没有忽略文件时应返回上级目录的规则")
+	}
+	// 与忽略文件同名的目录不是忽略文件
+	if err := os.Mkdir(filepath.Join(empty, ".gitignore"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = os.ReadDir(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := s.loadIgnoreRules(nil, empty, parent, entries); rules != parent {
+		t.Error("名为 .gitignore 的目录不应被当作忽略文件")
+	}
+}
@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// prevScan 上一次扫描结果的索引，用于增量扫描
+// 目录的修改时间只会在其直接子项增删/重命名时变化，
+// 因此修改时间未变的目录可以直接沿用上次记录的子项，无需重新读取。
+// 注意：原地修改文件内容不会改变目录修改时间，这类变化在增量模式下不会被发现。
+type prevScan struct {
+	dirModTimes map[string]int64         // 目录路径 -> 上次记录的修改时间
+	children    map[string][]*ScanRecord // 父目录路径 -> 上次记录的直接子项
+	recordCount int64                    // 加载的记录总数
+	badLines    int64                    // 无法解析的行数
+}
+
+// loadPrevScan 加载上一次扫描的输出文件
+func loadPrevScan(path string) (*prevScan, error) {
+	prev := &prevScan{
+		dirModTimes: make(map[string]int64),
+		children:    make(map[string][]*ScanRecord),
+	}
+
+	badLines, err := ReadScanRecords(path, func(rec *ScanRecord) error {
+		if rec.IsDir {
+			prev.dirModTimes[rec.Path] = rec.ModTime
+		}
+		parent := filepath.Dir(rec.Path)
+		prev.children[parent] = append(prev.children[parent], rec)
+		prev.recordCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	prev.badLines = badLines
+
+	return prev, nil
+}
+
+// unchangedChildren 如果目录自上次扫描以来没有变化，返回上次记录的子项
+// 没有子项记录的目录（空目录或上次无权限读取）总是重新读取，代价很小且可以纠正上次的错误
+func (p *prevScan) unchangedChildren(dirPath string, info os.FileInfo) ([]*ScanRecord, bool) {
+	modTime, ok := p.dirModTimes[dirPath]
+	if !ok || modTime != info.ModTime().Unix() {
+		return nil, false
+	}
+	children := p.children[dirPath]
+	if len(children) == 0 {
+		return nil, false
+	}
+	return children, true
+}
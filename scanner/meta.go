@@ -0,0 +1,412 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileMeta 开启 -meta 时记录的扩展元数据（在记录中与其他字段平铺）
+type FileMeta struct {
+	Uid   uint32 `json:"uid"`
+	Gid   uint32 `json:"gid"`
+	User  string `json:"user,omitempty"`
+	Group string `json:"group,omitempty"`
+	Mode  string `json:"mode"` // 权限位（八进制，含 setuid/setgid/sticky）
+	Inode uint64 `json:"inode"`
+	Nlink uint64 `json:"nlink"`
+	Atime int64  `json:"atime"`           // 访问时间
+	Ctime int64  `json:"ctime"`           // 元数据变化时间
+	Btime int64  `json:"btime,omitempty"` // 创建时间，平台或文件系统不支持时为 0
+}
+
+// OwnerTotal 一个用户拥有的文件合计
+type OwnerTotal struct {
+	Uid       uint32 `json:"uid"`
+	User      string `json:"user"`
+	Files     int64  `json:"files"`
+	Size      int64  `json:"size"`
+	DiskUsage int64  `json:"disk_usage"` // 硬链接只计一次
+}
+
+// permFilter -perm 条件，语法与 find 一致：644 完全相同，-644 包含全部位，/644 包含任意一位
+type permFilter struct {
+	bits uint32
+	mode byte // 0 完全相同，'-' 全部，'/' 任意
+}
+
+// timeFilter -newer/-older 条件
+type timeFilter struct {
+	field string // mtime/atime/ctime/btime
+	at    int64  // Unix 时间
+}
+
+// metaFilters 按元数据筛选文件的条件（由 Options 中的字符串编译而来）
+type metaFilters struct {
+	uids   map[uint32]struct{}
+	gids   map[uint32]struct{}
+	perm   *permFilter
+	newer  *timeFilter
+	older  *timeFilter
+	active bool
+}
+
+// compileMetaFilters 解析 -user/-group/-perm/-newer/-older
+func compileMetaFilters(options *Options, now time.Time) (*metaFilters, error) {
+	f := &metaFilters{}
+	for _, name := range options.Users {
+		uid, err := lookupUid(name)
+		if err != nil {
+			return nil, err
+		}
+		if f.uids == nil {
+			f.uids = make(map[uint32]struct{})
+		}
+		f.uids[uid] = struct{}{}
+	}
+	for _, name := range options.Groups {
+		gid, err := lookupGid(name)
+		if err != nil {
+			return nil, err
+		}
+		if f.gids == nil {
+			f.gids = make(map[uint32]struct{})
+		}
+		f.gids[gid] = struct{}{}
+	}
+	if options.Perm != "" {
+		p, err := parsePermFilter(options.Perm)
+		if err != nil {
+			return nil, err
+		}
+		f.perm = p
+	}
+	if options.Newer != "" {
+		t, err := parseTimeFilter(options.Newer, now)
+		if err != nil {
+			return nil, fmt.Errorf("-newer 参数错误: %v", err)
+		}
+		f.newer = t
+	}
+	if options.Older != "" {
+		t, err := parseTimeFilter(options.Older, now)
+		if err != nil {
+			return nil, fmt.Errorf("-older 参数错误: %v", err)
+		}
+		f.older = t
+	}
+	f.active = f.uids != nil || f.gids != nil || f.perm != nil || f.newer != nil || f.older != nil
+	return f, nil
+}
+
+// needsBirthTime 是否有条件用到创建时间（Linux 上需要额外的 statx 调用）
+func (f *metaFilters) needsBirthTime() bool {
+	return (f.newer != nil && f.newer.field == "btime") || (f.older != nil && f.older.field == "btime")
+}
+
+// match 判断文件是否符合全部条件
+func (f *metaFilters) match(m *FileMeta, modTime int64) bool {
+	if f.uids != nil {
+		if _, ok := f.uids[m.Uid]; !ok {
+			return false
+		}
+	}
+	if f.gids != nil {
+		if _, ok := f.gids[m.Gid]; !ok {
+			return false
+		}
+	}
+	if f.perm != nil {
+		bits, _ := strconv.ParseUint(m.Mode, 8, 32)
+		if !f.perm.match(uint32(bits)) {
+			return false
+		}
+	}
+	if f.newer != nil {
+		t := f.newer.value(m, modTime)
+		if t == 0 || t <= f.newer.at {
+			return false
+		}
+	}
+	if f.older != nil {
+		t := f.older.value(m, modTime)
+		if t == 0 || t >= f.older.at {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *permFilter) match(bits uint32) bool {
+	switch p.mode {
+	case '-':
+		return bits&p.bits == p.bits
+	case '/':
+		return p.bits == 0 || bits&p.bits != 0
+	}
+	return bits == p.bits
+}
+
+// value 取出条件使用的时间（不支持的时间为 0，按不匹配处理）
+func (t *timeFilter) value(m *FileMeta, modTime int64) int64 {
+	switch t.field {
+	case "atime":
+		return m.Atime
+	case "ctime":
+		return m.Ctime
+	case "btime":
+		return m.Btime
+	}
+	return modTime
+}
+
+// parsePermFilter 解析 -perm 参数（八进制，可带 - 或 / 前缀）
+func parsePermFilter(s string) (*permFilter, error) {
+	p := &permFilter{}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "/") {
+		p.mode = s[0]
+		s = s[1:]
+	}
+	bits, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || bits > 07777 {
+		return nil, fmt.Errorf("-perm 参数错误: %q 不是有效的八进制权限", s)
+	}
+	p.bits = uint32(bits)
+	return p, nil
+}
+
+// parseTimeFilter 解析时间条件: [mtime|atime|ctime|btime:]<时长或日期>
+// 时长相对当前时间（如 30d、12h、2w），日期为 2006-01-02 或 2006-01-02 15:04（本地时间）
+func parseTimeFilter(s string, now time.Time) (*timeFilter, error) {
+	t := &timeFilter{field: "mtime"}
+	if field, rest, ok := strings.Cut(s, ":"); ok {
+		switch field {
+		case "mtime", "atime", "ctime", "btime":
+			t.field, s = field, rest
+		}
+	}
+
+	if at, err := ParseLocalTime(s); err == nil {
+		t.at = at.Unix()
+		return t, nil
+	}
+	d, err := parseAge(s)
+	if err != nil {
+		return nil, err
+	}
+	t.at = now.Add(-d).Unix()
+	return t, nil
+}
+
+// ParseLocalTime 解析本地时间（2006-01-02 或 2006-01-02 15:04）
+func ParseLocalTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s", s)
+}
+
+// parseAge 解析时长，在 time.ParseDuration 的基础上支持 d（天）和 w（周）
+func parseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.ParseFloat(strings.TrimSpace(s[:len(s)-1]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间: %s", s)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间: %s（支持 30d、12h、2w 或 2006-01-02）", s)
+	}
+	return d, nil
+}
+
+// lookupUid 解析用户名或数字 uid
+func lookupUid(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, fmt.Errorf("未知的用户: %s", name)
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("用户 %s 的 uid 无效: %s", name, u.Uid)
+	}
+	return uint32(id), nil
+}
+
+// lookupGid 解析组名或数字 gid
+func lookupGid(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("未知的用户组: %s", name)
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("用户组 %s 的 gid 无效: %s", name, g.Gid)
+	}
+	return uint32(id), nil
+}
+
+// idNames 缓存 uid/gid 对应的名称，查不到时使用数字
+type idNames struct {
+	users  sync.Map // uint32 -> string
+	groups sync.Map // uint32 -> string
+}
+
+func (n *idNames) user(uid uint32) string {
+	if v, ok := n.users.Load(uid); ok {
+		return v.(string)
+	}
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	n.users.Store(uid, name)
+	return name
+}
+
+func (n *idNames) group(gid uint32) string {
+	if v, ok := n.groups.Load(gid); ok {
+		return v.(string)
+	}
+	name := strconv.FormatUint(uint64(gid), 10)
+	if g, err := user.LookupGroupId(name); err == nil {
+		name = g.Name
+	}
+	n.groups.Store(gid, name)
+	return name
+}
+
+// fileMeta 从文件信息中提取元数据，path 用于获取创建时间（Linux 需要 statx）
+func (s *Scanner) fileMeta(path string, info os.FileInfo) *FileMeta {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	m := &FileMeta{
+		Uid:   stat.Uid,
+		Gid:   stat.Gid,
+		User:  s.names.user(stat.Uid),
+		Group: s.names.group(stat.Gid),
+		Mode:  fmt.Sprintf("%04o", uint32(stat.Mode)&07777),
+		Inode: uint64(stat.Ino),
+		Nlink: uint64(stat.Nlink),
+	}
+	m.Atime, m.Ctime = statTimes(stat)
+	if s.options.Meta || s.filters.needsBirthTime() {
+		m.Btime = birthTime(path, stat, s.options.FollowSymlinks)
+	}
+	return m
+}
+
+// matchMeta 获取文件元数据并判断是否符合筛选条件；不需要元数据时返回 nil, true
+func (s *Scanner) matchMeta(path string, info os.FileInfo) (*FileMeta, bool) {
+	if !s.options.Meta && !s.filters.active {
+		return nil, true
+	}
+	m := s.fileMeta(path, info)
+	if m == nil {
+		return nil, !s.filters.active
+	}
+	if s.filters.active && !s.filters.match(m, info.ModTime().Unix()) {
+		return nil, false
+	}
+	if !s.options.Meta {
+		return nil, true
+	}
+	return m, true
+}
+
+// commitOwners 将已提交目录中的文件累加到所属用户（在 outputMu 保护下调用）
+func (s *Scanner) commitOwners(b *dirBatch) {
+	for _, node := range b.records {
+		if node.IsDir || node.FileMeta == nil {
+			continue
+		}
+		s.addOwner(node.FileMeta, node.Size, node.DiskUsage, node.IsHardlink)
+	}
+}
+
+func (s *Scanner) addOwner(m *FileMeta, size, disk int64, isHardlink bool) {
+	t, ok := s.owners[m.Uid]
+	if !ok {
+		t = &OwnerTotal{Uid: m.Uid, User: m.User}
+		s.owners[m.Uid] = t
+	}
+	t.Files++
+	t.Size += size
+	if !isHardlink {
+		t.DiskUsage += disk
+	}
+}
+
+// ownerSnapshot 返回各用户的合计，按磁盘占用从大到小排列（需持有 outputMu）
+func (s *Scanner) ownerSnapshot() []OwnerTotal {
+	list := make([]OwnerTotal, 0, len(s.owners))
+	for _, t := range s.owners {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DiskUsage != list[j].DiskUsage {
+			return list[i].DiskUsage > list[j].DiskUsage
+		}
+		return list[i].Uid < list[j].Uid
+	})
+	return list
+}
+
+// OwnerTotals 返回各用户拥有的文件合计（开启 Meta 时有效）
+func (s *Scanner) OwnerTotals() []OwnerTotal {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+	return s.ownerSnapshot()
+}
+
+// appendMeta 追加记录中的元数据字段（以逗号开头，追加在其他字段之后）
+func appendMeta(buf []byte, m *FileMeta) []byte {
+	buf = append(buf, `,"uid":`...)
+	buf = strconv.AppendUint(buf, uint64(m.Uid), 10)
+	buf = append(buf, `,"gid":`...)
+	buf = strconv.AppendUint(buf, uint64(m.Gid), 10)
+	buf = append(buf, `,"user":`...)
+	buf = appendJSONString(buf, m.User)
+	buf = append(buf, `,"group":`...)
+	buf = appendJSONString(buf, m.Group)
+	buf = append(buf, `,"mode":`...)
+	buf = appendJSONString(buf, m.Mode)
+	buf = append(buf, `,"inode":`...)
+	buf = strconv.AppendUint(buf, m.Inode, 10)
+	buf = append(buf, `,"nlink":`...)
+	buf = strconv.AppendUint(buf, m.Nlink, 10)
+	buf = append(buf, `,"atime":`...)
+	buf = strconv.AppendInt(buf, m.Atime, 10)
+	buf = append(buf, `,"ctime":`...)
+	buf = strconv.AppendInt(buf, m.Ctime, 10)
+	if m.Btime != 0 {
+		buf = append(buf, `,"btime":`...)
+		buf = strconv.AppendInt(buf, m.Btime, 10)
+	}
+	return buf
+}
@@ -0,0 +1,13 @@
+package scanner
+
+import "syscall"
+
+// statTimes 返回访问时间和元数据变化时间
+func statTimes(stat *syscall.Stat_t) (atime, ctime int64) {
+	return stat.Atimespec.Sec, stat.Ctimespec.Sec
+}
+
+// birthTime 返回创建时间（macOS 的 stat 直接提供）
+func birthTime(path string, stat *syscall.Stat_t, follow bool) int64 {
+	return stat.Birthtimespec.Sec
+}
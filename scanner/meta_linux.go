@@ -0,0 +1,28 @@
+package scanner
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// statTimes 返回访问时间和元数据变化时间
+func statTimes(stat *syscall.Stat_t) (atime, ctime int64) {
+	return int64(stat.Atim.Sec), int64(stat.Ctim.Sec)
+}
+
+// birthTime 通过 statx 获取创建时间（需要 Linux 4.11+ 且文件系统支持），获取不到时返回 0
+func birthTime(path string, stat *syscall.Stat_t, follow bool) int64 {
+	flags := unix.AT_STATX_DONT_SYNC
+	if !follow {
+		flags |= unix.AT_SYMLINK_NOFOLLOW
+	}
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, flags, unix.STATX_BTIME, &stx); err != nil {
+		return 0
+	}
+	if stx.Mask&unix.STATX_BTIME == 0 {
+		return 0
+	}
+	return stx.Btime.Sec
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !linux
+
+package scanner
+
+import "syscall"
+
+// statTimes 其他平台暂不读取访问时间和元数据变化时间
+func statTimes(stat *syscall.Stat_t) (atime, ctime int64) {
+	return 0, 0
+}
+
+// birthTime 其他平台暂不支持创建时间
+func birthTime(path string, stat *syscall.Stat_t, follow bool) int64 {
+	return 0
+}
@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// mountEntry 挂载表中的一项
+type mountEntry struct {
+	MountPoint string
+	FsType     string
+	Source     string // 设备或远程地址
+}
+
+// MountTotal 一个挂载点下扫描到的合计
+type MountTotal struct {
+	MountPoint string `json:"mount_point"`
+	FsType     string `json:"fs_type"`
+	Files      int64  `json:"files"`
+	Dirs       int64  `json:"dirs"`
+	Size       int64  `json:"size"`
+	DiskUsage  int64  `json:"disk_usage"`
+}
+
+// mountTracker 识别扫描中经过的挂载点并按挂载点累计
+// 子目录的设备号与所在目录不同时说明它是挂载点，这时才查挂载表；之后同一设备号上的目录都归属这个挂载点
+type mountTracker struct {
+	mu     sync.Mutex
+	table  []mountEntry           // 按挂载点路径从长到短排列，便于找最长前缀
+	byDev  map[uint64]*MountTotal // 设备号 -> 挂载点合计
+	totals map[string]*MountTotal // 挂载点路径 -> 合计（恢复扫描时设备号可能变化，按路径保存）
+}
+
+// newMountTracker 读取挂载表，读取失败时所有目录归入首次遇到时的路径，文件系统类型未知
+func newMountTracker() (*mountTracker, error) {
+	table, err := loadMountTable()
+	sort.Slice(table, func(i, j int) bool {
+		return len(table[i].MountPoint) > len(table[j].MountPoint)
+	})
+	return &mountTracker{
+		table:  table,
+		byDev:  make(map[uint64]*MountTotal),
+		totals: make(map[string]*MountTotal),
+	}, err
+}
+
+// lookup 返回设备号对应的挂载点，首次遇到时按 path 在挂载表中查找（path 应是该设备上遇到的第一个目录）
+func (m *mountTracker) lookup(dev uint64, path string) *MountTotal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.byDev[dev]; ok {
+		return t
+	}
+	entry := mountEntry{MountPoint: path, FsType: "unknown"}
+	for _, e := range m.table {
+		if IsPathWithin(path, e.MountPoint) {
+			entry = e
+			break
+		}
+	}
+	t, ok := m.totals[entry.MountPoint]
+	if !ok {
+		t = &MountTotal{MountPoint: entry.MountPoint, FsType: entry.FsType}
+		m.totals[entry.MountPoint] = t
+	}
+	m.byDev[dev] = t
+	return t
+}
+
+// commit 将已提交目录中的文件和子目录累加到所在挂载点（在 outputMu 保护下调用，与检查点一致）
+func (m *mountTracker) commit(b *dirBatch) {
+	if b.mount == nil {
+		return
+	}
+	m.mu.Lock()
+	b.mount.Files += b.files
+	b.mount.Dirs += b.dirs
+	b.mount.Size += b.size
+	b.mount.DiskUsage += b.disk
+	m.mu.Unlock()
+}
+
+// restore 恢复检查点中保存的合计
+func (m *mountTracker) restore(totals []MountTotal) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range totals {
+		t := totals[i]
+		m.totals[t.MountPoint] = &t
+	}
+}
+
+// snapshot 返回各挂载点的合计，按磁盘占用从大到小排列
+func (m *mountTracker) snapshot() []MountTotal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]MountTotal, 0, len(m.totals))
+	for _, t := range m.totals {
+		// 被跳过的挂载点也会登记（判断文件系统类型时），没有内容的不列出
+		if t.Files > 0 || t.Dirs > 0 {
+			list = append(list, *t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DiskUsage != list[j].DiskUsage {
+			return list[i].DiskUsage > list[j].DiskUsage
+		}
+		return list[i].MountPoint < list[j].MountPoint
+	})
+	return list
+}
+
+// matchFsType 判断文件系统类型是否在列表中，"fuse" 同时匹配 "fuse.sshfs" 这类子类型
+func matchFsType(fsType string, types []string) bool {
+	for _, t := range types {
+		if strings.EqualFold(fsType, t) || strings.HasPrefix(strings.ToLower(fsType), strings.ToLower(t)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipMountPoint 子目录的设备号与所在目录不同时说明它是挂载点，按 -xdev/-fstype 判断是否跳过
+func (s *Scanner) skipMountPoint(b *dirBatch, path string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || uint64(stat.Dev) == b.dev {
+		return false
+	}
+	dev := uint64(stat.Dev)
+
+	skip := s.options.OneFileSystem && dev != b.root.dev
+	if !skip && (len(s.options.OnlyFsTypes) > 0 || len(s.options.SkipFsTypes) > 0) {
+		fsType := s.mounts.lookup(dev, path).FsType
+		if len(s.options.OnlyFsTypes) > 0 && !matchFsType(fsType, s.options.OnlyFsTypes) {
+			skip = true
+		} else if matchFsType(fsType, s.options.SkipFsTypes) {
+			skip = true
+		}
+	}
+	if skip {
+		b.skippedMounts++
+		if s.options.Verbose {
+			s.sink.Notice(fmt.Sprintf("💽 跳过挂载点 %s", path))
+		}
+	}
+	return skip
+}
+
+// MountTotals 返回扫描经过的各挂载点的合计
+func (s *Scanner) MountTotals() []MountTotal {
+	if s.mounts == nil {
+		return nil
+	}
+	return s.mounts.snapshot()
+}
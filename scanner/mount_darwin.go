@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadMountTable 通过 getfsstat 读取挂载表
+func loadMountTable() ([]mountEntry, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取挂载表: %v", err)
+	}
+	// 两次调用之间可能有新的挂载，多留一些余量
+	buf := make([]unix.Statfs_t, n+8)
+	n, err = unix.Getfsstat(buf, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取挂载表: %v", err)
+	}
+
+	table := make([]mountEntry, 0, n)
+	for _, st := range buf[:n] {
+		table = append(table, mountEntry{
+			MountPoint: unix.ByteSliceToString(st.Mntonname[:]),
+			FsType:     unix.ByteSliceToString(st.Fstypename[:]),
+			Source:     unix.ByteSliceToString(st.Mntfromname[:]),
+		})
+	}
+	return table, nil
+}
@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// unescapeMountPath 还原挂载表中转义的空白字符（如 \040）
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			if _, err := fmt.Sscanf(s[i+1:i+4], "%o", &c); err == nil {
+				sb.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// parseMountInfo 解析 Linux 的 /proc/self/mountinfo
+// 格式: 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(data string) []mountEntry {
+	var table []mountEntry
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+		table = append(table, mountEntry{
+			MountPoint: unescapeMountPath(fields[4]),
+			FsType:     fields[sep+1],
+			Source:     unescapeMountPath(fields[sep+2]),
+		})
+	}
+	return table
+}
+
+// loadMountTable 读取当前进程可见的挂载表
+func loadMountTable() ([]mountEntry, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("无法读取挂载表: %v", err)
+	}
+	return parseMountInfo(string(data)), nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package scanner
+
+import "fmt"
+
+// loadMountTable 其他平台暂不支持读取挂载表，挂载点按设备号变化处的路径识别
+func loadMountTable() ([]mountEntry, error) {
+	return nil, fmt.Errorf("当前平台不支持读取挂载表")
+}
@@ -0,0 +1,325 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatJSONL    = "jsonl"    // JSON Lines（默认），可以被 analyze、diff、browse 和增量扫描读取
+	FormatCSV      = "csv"      // 带表头的 CSV
+	FormatTSV      = "tsv"      // 带表头的 TSV，字段中的制表符、换行和反斜杠用反斜杠转义
+	FormatNull     = "null"     // 每条记录只输出路径，以 NUL 结尾（配合 xargs -0）
+	FormatTemplate = "template" // 按 Template 输出
+)
+
+// recordEncoder 将一条文件/目录记录编码后追加到 buf
+type recordEncoder func(buf []byte, node *FileNode) []byte
+
+// newRecordEncoder 按输出格式创建记录编码器，header 为 CSV/TSV 的表头行
+func newRecordEncoder(options Options) (enc recordEncoder, header []byte, err error) {
+	switch options.Format {
+	case FormatJSONL:
+		return appendFileRecord, nil, nil
+	case FormatCSV, FormatTSV:
+		sep := byte(',')
+		appendField := appendCSVField
+		if options.Format == FormatTSV {
+			sep = '\t'
+			appendField = appendTSVField
+		}
+		// 扫描多个根目录时在 name 之后加一列 root
+		multiRoot := len(options.RootPaths) > 1
+		columns := append([]string(nil), tableColumns[:2]...)
+		if multiRoot {
+			columns = append(columns, "root")
+		}
+		columns = append(columns, tableColumns[2:]...)
+		if options.Meta {
+			columns = append(columns, metaColumns...)
+		}
+		header = []byte(strings.Join(columns, string(sep)) + "\n")
+		return func(buf []byte, node *FileNode) []byte {
+			return appendTableRow(buf, node, sep, appendField, multiRoot)
+		}, header, nil
+	case FormatNull:
+		return func(buf []byte, node *FileNode) []byte {
+			buf = append(buf, node.Path...)
+			return append(buf, 0)
+		}, nil, nil
+	case FormatTemplate:
+		if options.Template == "" {
+			return nil, nil, fmt.Errorf("template 格式需要指定模板")
+		}
+		enc, err := compileTemplate(options.Template, options.Meta, options.RootPath)
+		return enc, nil, err
+	}
+	return nil, nil, fmt.Errorf("不支持的输出格式: %s（支持 %s、%s、%s、%s、%s）",
+		options.Format, FormatJSONL, FormatCSV, FormatTSV, FormatNull, FormatTemplate)
+}
+
+// CSV/TSV 的列，与 JSON Lines 记录的字段名一致
+var (
+	tableColumns = []string{"path", "name", "size", "disk_usage", "mod_time", "is_dir", "is_hardlink", "is_sparse", "hash", "link_target"}
+	metaColumns  = []string{"uid", "gid", "user", "group", "mode", "inode", "nlink", "atime", "ctime", "btime"}
+)
+
+// appendTableRow 追加一行 CSV/TSV，路径按原始字节输出（不替换无效的 UTF-8）
+// root 为 true 时在 name 之后输出 root 列
+func appendTableRow(buf []byte, node *FileNode, sep byte, appendField func(buf []byte, s string, sep byte) []byte, root bool) []byte {
+	buf = appendField(buf, node.Path, sep)
+	buf = append(buf, sep)
+	buf = appendField(buf, node.Name, sep)
+	buf = append(buf, sep)
+	if root {
+		buf = appendField(buf, node.Root, sep)
+		buf = append(buf, sep)
+	}
+	buf = strconv.AppendInt(buf, node.Size, 10)
+	buf = append(buf, sep)
+	buf = strconv.AppendInt(buf, node.DiskUsage, 10)
+	buf = append(buf, sep)
+	buf = strconv.AppendInt(buf, node.ModTime, 10)
+	buf = append(buf, sep)
+	buf = strconv.AppendBool(buf, node.IsDir)
+	buf = append(buf, sep)
+	buf = strconv.AppendBool(buf, node.IsHardlink)
+	buf = append(buf, sep)
+	buf = strconv.AppendBool(buf, node.IsSparse)
+	buf = append(buf, sep)
+	buf = appendField(buf, node.Hash, sep)
+	buf = append(buf, sep)
+	buf = appendField(buf, node.LinkTarget, sep)
+	if m := node.FileMeta; m != nil {
+		buf = append(buf, sep)
+		buf = strconv.AppendUint(buf, uint64(m.Uid), 10)
+		buf = append(buf, sep)
+		buf = strconv.AppendUint(buf, uint64(m.Gid), 10)
+		buf = append(buf, sep)
+		buf = appendField(buf, m.User, sep)
+		buf = append(buf, sep)
+		buf = appendField(buf, m.Group, sep)
+		buf = append(buf, sep)
+		buf = appendField(buf, m.Mode, sep)
+		buf = append(buf, sep)
+		buf = strconv.AppendUint(buf, m.Inode, 10)
+		buf = append(buf, sep)
+		buf = strconv.AppendUint(buf, m.Nlink, 10)
+		buf = append(buf, sep)
+		buf = strconv.AppendInt(buf, m.Atime, 10)
+		buf = append(buf, sep)
+		buf = strconv.AppendInt(buf, m.Ctime, 10)
+		buf = append(buf, sep)
+		if m.Btime != 0 {
+			buf = strconv.AppendInt(buf, m.Btime, 10)
+		}
+	}
+	return append(buf, '\n')
+}
+
+// appendCSVField 追加一个 CSV 字段，含分隔符、引号、换行或首尾空白时加引号（RFC 4180）
+func appendCSVField(buf []byte, s string, sep byte) []byte {
+	quote := s != "" && (s[0] == ' ' || s[0] == '\t' || s[len(s)-1] == ' ')
+	for i := 0; i < len(s) && !quote; i++ {
+		switch s[i] {
+		case sep, '"', '\r', '\n':
+			quote = true
+		}
+	}
+	if !quote {
+		return append(buf, s...)
+	}
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' {
+			buf = append(buf, '"')
+		}
+		buf = append(buf, s[i])
+	}
+	return append(buf, '"')
+}
+
+// appendTSVField 追加一个 TSV 字段，制表符、换行和反斜杠转义为 \t、\n、\r、\\
+func appendTSVField(buf []byte, s string, sep byte) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// templateField 模板中可以使用的字段
+type templateField struct {
+	meta   bool // 需要开启 Meta
+	format func(buf []byte, node *FileNode, arg string) []byte
+}
+
+// 时间字段的参数为 Go 时间格式（如 {mtime:2006-01-02}），不带参数时输出 Unix 时间戳
+func timeField(get func(node *FileNode) int64) func(buf []byte, node *FileNode, arg string) []byte {
+	return func(buf []byte, node *FileNode, arg string) []byte {
+		t := get(node)
+		if arg == "" {
+			return strconv.AppendInt(buf, t, 10)
+		}
+		if t == 0 {
+			return buf
+		}
+		return time.Unix(t, 0).AppendFormat(buf, arg)
+	}
+}
+
+// 大小字段的参数为 h 时输出易读的大小（如 1.5 GB）
+func sizeField(get func(node *FileNode) int64) func(buf []byte, node *FileNode, arg string) []byte {
+	return func(buf []byte, node *FileNode, arg string) []byte {
+		if arg == "h" {
+			return append(buf, FormatSize(get(node))...)
+		}
+		return strconv.AppendInt(buf, get(node), 10)
+	}
+}
+
+func stringField(get func(node *FileNode) string) func(buf []byte, node *FileNode, arg string) []byte {
+	return func(buf []byte, node *FileNode, arg string) []byte {
+		return append(buf, get(node)...)
+	}
+}
+
+func uintField(get func(m *FileMeta) uint64) func(buf []byte, node *FileNode, arg string) []byte {
+	return func(buf []byte, node *FileNode, arg string) []byte {
+		return strconv.AppendUint(buf, get(node.FileMeta), 10)
+	}
+}
+
+var templateFields = map[string]templateField{
+	"path":        {format: stringField(func(n *FileNode) string { return n.Path })},
+	"name":        {format: stringField(func(n *FileNode) string { return n.Name })},
+	"dir":         {format: stringField(func(n *FileNode) string { return filepath.Dir(n.Path) })},
+	"root":        {format: stringField(func(n *FileNode) string { return n.Root })},
+	"ext":         {format: stringField(func(n *FileNode) string { return filepath.Ext(n.Name) })},
+	"hash":        {format: stringField(func(n *FileNode) string { return n.Hash })},
+	"link_target": {format: stringField(func(n *FileNode) string { return n.LinkTarget })},
+	"size":        {format: sizeField(func(n *FileNode) int64 { return n.Size })},
+	"disk_usage":  {format: sizeField(func(n *FileNode) int64 { return n.DiskUsage })},
+	"mtime":       {format: timeField(func(n *FileNode) int64 { return n.ModTime })},
+	"is_dir": {format: func(buf []byte, n *FileNode, arg string) []byte {
+		return strconv.AppendBool(buf, n.IsDir)
+	}},
+	"type": {format: func(buf []byte, n *FileNode, arg string) []byte {
+		switch {
+		case n.IsDir:
+			return append(buf, 'd')
+		case n.LinkTarget != "":
+			return append(buf, 'l')
+		}
+		return append(buf, 'f')
+	}},
+	"uid":   {meta: true, format: uintField(func(m *FileMeta) uint64 { return uint64(m.Uid) })},
+	"gid":   {meta: true, format: uintField(func(m *FileMeta) uint64 { return uint64(m.Gid) })},
+	"inode": {meta: true, format: uintField(func(m *FileMeta) uint64 { return m.Inode })},
+	"nlink": {meta: true, format: uintField(func(m *FileMeta) uint64 { return m.Nlink })},
+	"user":  {meta: true, format: stringField(func(n *FileNode) string { return n.User })},
+	"group": {meta: true, format: stringField(func(n *FileNode) string { return n.Group })},
+	"mode":  {meta: true, format: stringField(func(n *FileNode) string { return n.Mode })},
+	"atime": {meta: true, format: timeField(func(n *FileNode) int64 { return n.Atime })},
+	"ctime": {meta: true, format: timeField(func(n *FileNode) int64 { return n.Ctime })},
+	"btime": {meta: true, format: timeField(func(n *FileNode) int64 { return n.Btime })},
+}
+
+// templatePart 模板的一段：固定文本或一个字段
+type templatePart struct {
+	literal []byte
+	field   func(buf []byte, node *FileNode, arg string) []byte
+	arg     string
+}
+
+// compileTemplate 解析模板：{字段} 或 {字段:参数} 替换为记录的值，{{ 和 }} 表示花括号本身，
+// 支持 \n、\t、\r、\0 和 \\ 转义；与 find -printf 一样不会自动换行
+// 只有一个根目录时记录中没有 root，{root} 输出 rootPath
+func compileTemplate(tmpl string, meta bool, rootPath string) (recordEncoder, error) {
+	var parts []templatePart
+	var literal []byte
+	flush := func() {
+		if len(literal) > 0 {
+			parts = append(parts, templatePart{literal: literal})
+			literal = nil
+		}
+	}
+
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '\\' && i+1 < len(tmpl):
+			i++
+			switch tmpl[i] {
+			case 'n':
+				literal = append(literal, '\n')
+			case 't':
+				literal = append(literal, '\t')
+			case 'r':
+				literal = append(literal, '\r')
+			case '0':
+				literal = append(literal, 0)
+			case '\\':
+				literal = append(literal, '\\')
+			default:
+				literal = append(literal, '\\', tmpl[i])
+			}
+		case c == '{' && i+1 < len(tmpl) && tmpl[i+1] == '{', c == '}' && i+1 < len(tmpl) && tmpl[i+1] == '}':
+			literal = append(literal, c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("模板错误: 第 %d 个字符处的 { 没有对应的 }", i+1)
+			}
+			name, arg, _ := strings.Cut(tmpl[i+1:i+end], ":")
+			field, ok := templateFields[name]
+			if !ok {
+				return nil, fmt.Errorf("模板错误: 未知字段 {%s}", name)
+			}
+			if field.meta && !meta {
+				return nil, fmt.Errorf("模板错误: 字段 {%s} 需要开启元数据记录（-meta）", name)
+			}
+			format := field.format
+			if name == "root" {
+				format = func(buf []byte, n *FileNode, arg string) []byte {
+					if n.Root == "" {
+						return append(buf, rootPath...)
+					}
+					return append(buf, n.Root...)
+				}
+			}
+			flush()
+			parts = append(parts, templatePart{field: format, arg: arg})
+			i += end
+		default:
+			literal = append(literal, c)
+		}
+	}
+	flush()
+
+	return func(buf []byte, node *FileNode) []byte {
+		for _, p := range parts {
+			if p.field != nil {
+				buf = p.field(buf, node, p.arg)
+			} else {
+				buf = append(buf, p.literal...)
+			}
+		}
+		return buf
+	}, nil
+}
@@ -0,0 +1,220 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendCSVField(t *testing.T) {
+	tests := []struct {
+		in   string
+		sep  byte
+		want string
+	}{
+		{"", ',', ""},
+		{"plain.txt", ',', "plain.txt"},
+		{"a,b", ',', `"a,b"`},
+		{"a,b", ';', "a,b"},
+		{"a;b", ';', `"a;b"`},
+		{`say "hi"`, ',', `"say ""hi"""`},
+		{`"`, ',', `""""`},
+		{"line1\nline2", ',', "\"line1\nline2\""},
+		{"cr\rlf", ',', "\"cr\rlf\""},
+		{" leading", ',', `" leading"`},
+		{"trailing ", ',', `"trailing "`},
+		{"\tindent", ',', "\"\tindent\""},
+		{"mid space", ',', "mid space"},
+		{"中文 名称.txt", ',', "中文 名称.txt"},
+		{"bad\xffutf8", ',', "bad\xffutf8"},
+	}
+	for _, tt := range tests {
+		if got := string(appendCSVField(nil, tt.in, tt.sep)); got != tt.want {
+			t.Errorf("appendCSVField(%q, %q) = %q，应为 %q", tt.in, tt.sep, got, tt.want)
+		}
+	}
+}
+
+// CSV 字段应能被标准的 CSV 解析器还原
+func TestAppendCSVFieldRoundTrip(t *testing.T) {
+	fields := []string{"", "a,b", `q"uote`, "new\nline", " both ", "\ttab", `"",""`, "/Users/me/It's \"old\", 2024"}
+	var line []byte
+	for i, f := range fields {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = appendCSVField(line, f, ',')
+	}
+	line = append(line, '\n')
+
+	got, err := csv.NewReader(strings.NewReader(string(line))).Read()
+	if err != nil {
+		t.Fatalf("无法解析 %q: %v", line, err)
+	}
+	if len(got) != len(fields) {
+		t.Fatalf("字段数 = %d，应为 %d: %q", len(got), len(fields), got)
+	}
+	for i := range fields {
+		if got[i] != fields[i] {
+			t.Errorf("字段 %d = %q，应为 %q", i, got[i], fields[i])
+		}
+	}
+}
+
+func TestAppendTSVField(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"a\tb", `a\tb`},
+		{"a\nb", `a\nb`},
+		{"a\rb", `a\rb`},
+		{`back\slash`, `back\\slash`},
+		{`\t literal`, `\\t literal`},
+		{`"quoted", comma`, `"quoted", comma`},
+		{" spaces ", " spaces "},
+	}
+	for _, tt := range tests {
+		if got := string(appendTSVField(nil, tt.in, '\t')); got != tt.want {
+			t.Errorf("appendTSVField(%q) = %q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTableEncoder(t *testing.T) {
+	node := &FileNode{
+		Path:       "/r/a,b/x\ty.txt",
+		Name:       "x\ty.txt",
+		Size:       10,
+		DiskUsage:  4096,
+		ModTime:    1700000000,
+		IsHardlink: true,
+		Root:       "/r",
+	}
+	tests := []struct {
+		name       string
+		options    Options
+		wantHeader string
+		wantRow    string
+	}{
+		{
+			name:       "CSV",
+			options:    Options{Format: FormatCSV},
+			wantHeader: "path,name,size,disk_usage,mod_time,is_dir,is_hardlink,is_sparse,hash,link_target\n",
+			wantRow:    "\"/r/a,b/x\ty.txt\",x\ty.txt,10,4096,1700000000,false,true,false,,\n",
+		},
+		{
+			name:       "TSV",
+			options:    Options{Format: FormatTSV},
+			wantHeader: "path\tname\tsize\tdisk_usage\tmod_time\tis_dir\tis_hardlink\tis_sparse\thash\tlink_target\n",
+			wantRow:    "/r/a,b/x\\ty.txt\tx\\ty.txt\t10\t4096\t1700000000\tfalse\ttrue\tfalse\t\t\n",
+		},
+		{
+			name:       "CSV-多个根目录",
+			options:    Options{Format: FormatCSV, RootPaths: []string{"/r", "/s"}},
+			wantHeader: "path,name,root,size,disk_usage,mod_time,is_dir,is_hardlink,is_sparse,hash,link_target\n",
+			wantRow:    "\"/r/a,b/x\ty.txt\",x\ty.txt,/r,10,4096,1700000000,false,true,false,,\n",
+		},
+	}
+	for _, tt := range tests {
+		enc, header, err := newRecordEncoder(tt.options)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(header) != tt.wantHeader {
+			t.Errorf("%s: 表头 = %q，应为 %q", tt.name, header, tt.wantHeader)
+		}
+		if got := string(enc(nil, node)); got != tt.wantRow {
+			t.Errorf("%s: 记录 = %q，应为 %q", tt.name, got, tt.wantRow)
+		}
+	}
+}
+
+func TestTableEncoderMeta(t *testing.T) {
+	enc, header, err := newRecordEncoder(Options{Format: FormatCSV, Meta: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &FileNode{
+		Path: "/r/f", Name: "f",
+		FileMeta: &FileMeta{Uid: 501, Gid: 20, User: "me", Group: "staff", Mode: "-rw-r--r--", Inode: 42, Nlink: 1, Atime: 1, Ctime: 2},
+	}
+	columns := strings.Split(strings.TrimSuffix(string(header), "\n"), ",")
+	row, err := csv.NewReader(strings.NewReader(string(enc(nil, node)))).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(row) != len(columns) {
+		t.Fatalf("列数 = %d，表头有 %d 列", len(row), len(columns))
+	}
+	want := map[string]string{"uid": "501", "gid": "20", "user": "me", "group": "staff", "mode": "-rw-r--r--", "inode": "42", "nlink": "1", "atime": "1", "ctime": "2", "btime": ""}
+	for i, col := range columns {
+		if w, ok := want[col]; ok && row[i] != w {
+			t.Errorf("%s = %q，应为 %q", col, row[i], w)
+		}
+	}
+}
+
+func TestNullEncoder(t *testing.T) {
+	enc, header, err := newRecordEncoder(Options{Format: FormatNull})
+	if err != nil || header != nil {
+		t.Fatalf("newRecordEncoder = %v, %v", header, err)
+	}
+	if got := string(enc(nil, &FileNode{Path: "/r/with\nnewline"})); got != "/r/with\nnewline\x00" {
+		t.Errorf("记录 = %q", got)
+	}
+}
+
+func TestCompileTemplate(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local).Unix()
+	file := &FileNode{Path: "/r/docs/a.pdf", Name: "a.pdf", Size: 1536, DiskUsage: 4096, ModTime: mtime}
+	tests := []struct {
+		tmpl string
+		node *FileNode
+		want string
+	}{
+		{`{path}\n`, file, "/r/docs/a.pdf\n"},
+		{`{size}\t{disk_usage}\t{name}\0`, file, "1536\t4096\ta.pdf\x00"},
+		{`{size:h}`, file, "1.50 KB"},
+		{`{dir} {ext} {type}`, file, "/r/docs .pdf f"},
+		{`{type}`, &FileNode{IsDir: true}, "d"},
+		{`{type}`, &FileNode{LinkTarget: "/x"}, "l"},
+		{`{mtime:2006-01-02}`, file, "2024-03-01"},
+		{`{mtime}`, file, strconv.FormatInt(mtime, 10)},
+		{`{{{name}}}`, file, "{a.pdf}"},
+		{`a\\b\x`, file, `a\b\x`},
+		{`{root}`, file, "/r"},
+		{`{root}`, &FileNode{Root: "/s"}, "/s"},
+		{`tail\`, file, `tail\`},
+	}
+	for _, tt := range tests {
+		enc, err := compileTemplate(tt.tmpl, false, "/r")
+		if err != nil {
+			t.Errorf("%q: %v", tt.tmpl, err)
+			continue
+		}
+		if got := string(enc(nil, tt.node)); got != tt.want {
+			t.Errorf("%q = %q，应为 %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestCompileTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{"{path", "{nope}", "{uid}", "{size}{"} {
+		if _, err := compileTemplate(tmpl, false, "/r"); err == nil {
+			t.Errorf("%q: 应返回错误", tmpl)
+		}
+	}
+	if _, err := compileTemplate("{uid}:{user}", true, "/r"); err != nil {
+		t.Errorf("开启 meta 时 {uid} 应可用: %v", err)
+	}
+	if _, _, err := newRecordEncoder(Options{Format: FormatTemplate}); err == nil {
+		t.Error("template 格式没有模板时应返回错误")
+	}
+	if _, _, err := newRecordEncoder(Options{Format: "xml"}); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
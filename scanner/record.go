@@ -0,0 +1,303 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 带 type 字段的记录类型（普通文件/目录记录没有 type 字段）
+const (
+	recordTypeDirSummary = "dir_summary"  // 目录汇总：子项大小、磁盘占用和数量的递归合计
+	recordTypeHeader     = "scan_header"  // 文件头：文件格式、扫描路径和开始时间，总是第一条记录
+	recordTypeSummary    = "scan_summary" // 扫描结束记录：是否完整、统计和结束原因，总是最后一条记录
+)
+
+// 输出文件的格式标识和版本，写在文件头记录中
+const (
+	outputFormat        = "mac-file-search"
+	outputFormatVersion = 1
+)
+
+// ScanHeader 输出文件的文件头记录
+type ScanHeader struct {
+	Type      string   `json:"type"`
+	Format    string   `json:"format"`
+	Version   int      `json:"version"`
+	RootPath  string   `json:"root_path"`       // 扫描根目录（多个根目录时为第一个）
+	Roots     []string `json:"roots,omitempty"` // 扫描多个根目录时的全部根目录
+	StartTime string   `json:"start_time"`      // RFC 3339 格式
+}
+
+// ScanSummary 输出文件末尾的扫描结束记录
+// 中断的扫描也会写入（Complete 为 false），从检查点恢复时会被截掉，扫描结束后重新写入
+type ScanSummary struct {
+	Type     string  `json:"type"`
+	Complete bool    `json:"complete"`
+	Reason   string  `json:"reason"`   // 完整结束时为 "completed"，否则为中断原因
+	EndTime  string  `json:"end_time"` // RFC 3339 格式
+	Duration float64 `json:"duration"` // 本次运行的耗时（秒），恢复扫描时不含之前的运行
+	*Stats
+}
+
+// ScanRecord 扫描输出文件（JSON Lines）中的一条记录
+type ScanRecord struct {
+	Type        string `json:"type,omitempty"`
+	Path        string `json:"path"`
+	Name        string `json:"name"`
+	Root        string `json:"root,omitempty"` // 所在的扫描根目录（只在扫描多个根目录时记录）
+	Size        int64  `json:"size"`
+	DiskUsage   int64  `json:"disk_usage"`
+	ModTime     int64  `json:"mod_time"`
+	IsDir       bool   `json:"is_dir"`
+	IsSparse    bool   `json:"is_sparse,omitempty"`
+	IsHardlink  bool   `json:"is_hardlink,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	HashSampled bool   `json:"hash_sampled,omitempty"`
+	FileCount   int64  `json:"file_count,omitempty"`
+	DirCount    int64  `json:"dir_count,omitempty"`
+	LinkTarget  string `json:"link_target,omitempty"`
+	InodeKey    string `json:"inode_key,omitempty"` // 参与硬链接去重的文件的 "dev:ino"
+	*FileMeta
+
+	// 路径或链接目标不是有效的 UTF-8 时，JSON 字符串中的无效字节被替换为 U+FFFD，原始字节保存在这两个字段中（base64）
+	// 读取时会还原到 Path、Name 和 LinkTarget
+	PathBytes       []byte `json:"path_bytes,omitempty"`
+	LinkTargetBytes []byte `json:"link_target_bytes,omitempty"`
+}
+
+// ParseScanRecord 解析一行记录，还原非 UTF-8 的路径
+func ParseScanRecord(line []byte) (*ScanRecord, error) {
+	var rec ScanRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, err
+	}
+	if rec.PathBytes != nil {
+		rec.Path = string(rec.PathBytes)
+		rec.Name = filepath.Base(rec.Path)
+	}
+	if rec.LinkTargetBytes != nil {
+		rec.LinkTarget = string(rec.LinkTargetBytes)
+	}
+	return &rec, nil
+}
+
+// ReadScanRecords 逐行读取扫描输出文件，对每条文件/目录记录调用 fn
+// 注释行（# 开头，旧版本的文件头）、空行和带 type 的记录（如文件头、目录汇总）会被跳过，无法解析的行计入 badLines 后跳过
+func ReadScanRecords(path string, fn func(rec *ScanRecord) error) (badLines int64, err error) {
+	f, err := OpenScanFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("无法打开扫描结果文件: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer，与 GUI 导入保持一致
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] == '#' || strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+
+		rec, err := ParseScanRecord(line)
+		if err != nil {
+			badLines++
+			continue
+		}
+		if rec.Type != "" {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return badLines, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return badLines, fmt.Errorf("读取扫描结果文件失败: %v", err)
+	}
+	return badLines, nil
+}
+
+// ReadScanHeader 读取输出文件的文件头记录，旧版本以 # 注释行作为文件头的文件返回 nil
+func ReadScanHeader(path string) (*ScanHeader, error) {
+	f, err := OpenScanFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开扫描结果文件: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !isRecordLine(line) {
+			continue
+		}
+		var header ScanHeader
+		if json.Unmarshal(line, &header) != nil || header.Type != recordTypeHeader {
+			return nil, nil
+		}
+		return &header, nil
+	}
+	return nil, scanner.Err()
+}
+
+// ReadScanSummary 读取输出文件末尾的扫描结束记录，没有时（扫描进程被强制结束或旧版本的文件）返回 nil
+func ReadScanSummary(path string) (*ScanSummary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开扫描结果文件: %v", err)
+	}
+	defer f.Close()
+
+	r, err := newScanFileReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("无法解压 %s: %v", path, err)
+	}
+	var line []byte
+	if r.compressed {
+		// 压缩文件只能从头解压，逐行读到最后一条记录
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			if isRecordLine(scanner.Bytes()) {
+				line = append(line[:0], scanner.Bytes()...)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取扫描结果文件失败: %v", err)
+		}
+	} else {
+		// 结束记录只有一行，读取文件末尾一小段即可
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		offset := info.Size() - 64*1024
+		if offset < 0 {
+			offset = 0
+		}
+		tail := make([]byte, info.Size()-offset)
+		if _, err := f.ReadAt(tail, offset); err != nil {
+			return nil, fmt.Errorf("读取扫描结果文件失败: %v", err)
+		}
+		tail = bytes.TrimRight(tail, "\r\n")
+		line = tail[bytes.LastIndexByte(tail, '\n')+1:]
+	}
+
+	var summary ScanSummary
+	if json.Unmarshal(line, &summary) != nil || summary.Type != recordTypeSummary {
+		return nil, nil
+	}
+	return &summary, nil
+}
+
+// writeRecord 将一条带 type 的记录写成一行 JSON
+func writeRecord(w io.Writer, rec interface{}) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// appendJSONString 将字符串编码为 JSON 字符串追加到 dst
+// 引号、反斜杠和控制字符转义，无效的 UTF-8 字节替换为 U+FFFD（与 encoding/json 一致），其他字符原样保留
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i++
+			start = i
+			continue
+		}
+		// U+2028/U+2029 在 JSON 中合法，但部分 JavaScript 解析器会当作换行
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\u202`...)
+			dst = append(dst, hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// appendPathField 追加 "key":"path" 字段，路径不是有效的 UTF-8 时再追加 "key_bytes" 字段保存 base64 编码的原始字节
+func appendPathField(dst []byte, key, path string) []byte {
+	dst = append(dst, '"')
+	dst = append(dst, key...)
+	dst = append(dst, '"', ':')
+	dst = appendJSONString(dst, path)
+	if !utf8.ValidString(path) {
+		dst = append(dst, ',', '"')
+		dst = append(dst, key...)
+		dst = append(dst, `_bytes":"`...)
+		dst = append(dst, base64.StdEncoding.EncodeToString([]byte(path))...)
+		dst = append(dst, '"')
+	}
+	return dst
+}
+
+// IsPathWithin 判断 path 是否等于 dir 或位于 dir 之下
+func IsPathWithin(path, dir string) bool {
+	if path == dir || dir == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// WidenRoot 扩大公共上级目录 root，使其包含记录 path（root 为空时取 path 的上级目录）
+func WidenRoot(root, path string) string {
+	if root == "" {
+		return filepath.Dir(path)
+	}
+	for !IsPathWithin(path, root) {
+		parent := filepath.Dir(root)
+		if parent == root {
+			break
+		}
+		root = parent
+	}
+	return root
+}
@@ -0,0 +1,280 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// DirTotal 一个目录的递归汇总
+type DirTotal struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	DiskUsage int64  `json:"disk_usage"` // 硬链接只计一次，与总计口径一致
+	ModTime   int64  `json:"mod_time"`
+	FileCount int64  `json:"file_count"`
+	DirCount  int64  `json:"dir_count"`
+}
+
+// dirAgg 一个尚未完成汇总的目录
+type dirAgg struct {
+	path                    string
+	parent                  *dirAgg
+	modTime                 int64
+	size, disk, files, dirs int64
+	pending                 int // 目录自身尚未提交时为 1，加上尚未完成汇总的子目录数
+}
+
+// dirAggregator 在扫描过程中流式汇总目录大小，不需要完整的文件树
+// 目录自身提交且所有子目录都完成汇总后，该目录的合计就确定了：回调 onDone、累加到父目录并释放，
+// 因此内存中只保留正在扫描的目录
+type dirAggregator struct {
+	mu     sync.Mutex
+	roots  map[string]struct{} // 扫描根目录，汇总到这里为止
+	dirs   map[string]*dirAgg
+	onDone func(d DirTotal) // 在持有锁时调用
+}
+
+func newDirAggregator(rootPaths []string, onDone func(d DirTotal)) *dirAggregator {
+	a := &dirAggregator{
+		roots:  make(map[string]struct{}, len(rootPaths)),
+		dirs:   make(map[string]*dirAgg),
+		onDone: onDone,
+	}
+	for _, path := range rootPaths {
+		a.roots[path] = struct{}{}
+	}
+	return a
+}
+
+// get 获取目录的汇总状态，不存在时创建并挂到父目录上（需持有锁）
+func (a *dirAggregator) get(path string) *dirAgg {
+	if d, ok := a.dirs[path]; ok {
+		return d
+	}
+	d := &dirAgg{path: path, pending: 1}
+	_, isRoot := a.roots[path]
+	if parentPath := filepath.Dir(path); !isRoot && parentPath != path {
+		d.parent = a.get(parentPath)
+		d.parent.pending++
+	}
+	a.dirs[path] = d
+	return d
+}
+
+// commit 登记目录自身的文件和子目录（在 commitBatch 中、子目录入队之前调用）
+func (a *dirAggregator) commit(b *dirBatch) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	d := a.get(b.dirPath)
+	if b.modTime != 0 {
+		d.modTime = b.modTime
+	}
+	for _, path := range b.subdirs {
+		a.get(path)
+	}
+	for _, node := range b.records {
+		if !node.IsDir {
+			d.addFile(node.Size, node.DiskUsage, node.IsHardlink)
+		}
+	}
+	a.finish(d)
+}
+
+// replay 恢复扫描时登记检查点之前已写入的记录，全部登记后调用 finishReplay
+func (a *dirAggregator) replay(rec *ScanRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if rec.IsDir {
+		a.get(rec.Path).modTime = rec.ModTime
+		return
+	}
+	a.get(filepath.Dir(rec.Path)).addFile(rec.Size, rec.DiskUsage, rec.IsHardlink)
+}
+
+// finishReplay 将检查点之前已提交的目录（不在待扫描列表中的）标记为已提交
+func (a *dirAggregator) finishReplay(pending []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pendingSet := make(map[string]struct{}, len(pending))
+	for _, path := range pending {
+		pendingSet[path] = struct{}{}
+		a.get(path)
+	}
+
+	// 目录自身提交前不会完成汇总，所以遍历快照时不会重复处理已释放的目录
+	committed := make([]*dirAgg, 0, len(a.dirs))
+	for path, d := range a.dirs {
+		if _, ok := pendingSet[path]; !ok {
+			committed = append(committed, d)
+		}
+	}
+	for _, d := range committed {
+		a.finish(d)
+	}
+}
+
+func (d *dirAgg) addFile(size, disk int64, isHardlink bool) {
+	d.files++
+	d.size += size
+	if !isHardlink {
+		d.disk += disk
+	}
+}
+
+// finish 完成目录的一项工作，完成汇总时逐级向上累加（需持有锁）
+func (a *dirAggregator) finish(d *dirAgg) {
+	for {
+		d.pending--
+		if d.pending > 0 {
+			return
+		}
+		delete(a.dirs, d.path)
+		if a.onDone != nil {
+			a.onDone(DirTotal{Path: d.path, Size: d.size, DiskUsage: d.disk, ModTime: d.modTime, FileCount: d.files, DirCount: d.dirs})
+		}
+
+		p := d.parent
+		if p == nil {
+			return
+		}
+		p.size += d.size
+		p.disk += d.disk
+		p.files += d.files
+		p.dirs += d.dirs + 1
+		d = p
+	}
+}
+
+// dirDone 目录完成汇总：写入汇总记录并登记到排行
+func (s *Scanner) dirDone(d DirTotal) {
+	if r := s.rootAt(d.Path); r != nil && d.ModTime == 0 {
+		// 恢复扫描时根目录不会重新扫描
+		d.ModTime = r.modTime
+	}
+	if s.summaries != nil {
+		s.summaries.add(d)
+	}
+	if s.top != nil {
+		s.top.addDir(d)
+	}
+}
+
+// summarySpool 暂存目录汇总记录，扫描结束后统一追加到输出文件末尾
+// 汇总记录在目录完成时产生，先写入临时文件而不是输出文件，检查点之前的输出中就不会有汇总记录，
+// 恢复扫描时重放已写入的记录即可重新得到全部汇总，不会重复
+type summarySpool struct {
+	file   *os.File
+	writer *bufio.Writer
+	buf    []byte // 编码记录的缓冲区，重复使用
+	err    error
+}
+
+// newSummarySpool 在输出文件旁创建暂存文件（被强制中断时残留的暂存文件会在下次扫描时被覆盖），
+// 输出到标准输出时使用系统临时目录
+func newSummarySpool(outputFile string) (*summarySpool, error) {
+	var f *os.File
+	var err error
+	if outputFile == "-" {
+		f, err = os.CreateTemp("", "mac-file-search-summary-*")
+	} else {
+		f, err = os.Create(outputFile + ".summary-tmp")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法创建目录汇总临时文件: %v", err)
+	}
+	return &summarySpool{file: f, writer: bufio.NewWriterSize(f, 256*1024)}, nil
+}
+
+// add 写入一条汇总记录（由 dirAggregator 在持有锁时调用）
+func (p *summarySpool) add(d DirTotal) {
+	if p.err != nil {
+		return
+	}
+	p.buf = appendDirSummary(p.buf[:0], d)
+	_, p.err = p.writer.Write(p.buf)
+}
+
+// appendTo 将全部汇总记录追加到 w
+func (p *summarySpool) appendTo(w io.Writer) error {
+	if p.err != nil {
+		return fmt.Errorf("写入目录汇总临时文件失败: %v", p.err)
+	}
+	if err := p.writer.Flush(); err != nil {
+		return fmt.Errorf("写入目录汇总临时文件失败: %v", err)
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, p.file); err != nil {
+		return fmt.Errorf("写入目录汇总失败: %v", err)
+	}
+	return nil
+}
+
+// close 删除临时文件
+func (p *summarySpool) close() {
+	p.file.Close()
+	os.Remove(p.file.Name())
+}
+
+// appendDirSummary 将一条目录汇总记录编码为一行 JSON 追加到 buf
+func appendDirSummary(buf []byte, d DirTotal) []byte {
+	buf = append(buf, `{"type":"`+recordTypeDirSummary+`",`...)
+	buf = appendPathField(buf, "path", d.Path)
+	buf = append(buf, `,"name":`...)
+	buf = appendJSONString(buf, filepath.Base(d.Path))
+	buf = append(buf, `,"size":`...)
+	buf = strconv.AppendInt(buf, d.Size, 10)
+	buf = append(buf, `,"disk_usage":`...)
+	buf = strconv.AppendInt(buf, d.DiskUsage, 10)
+	buf = append(buf, `,"mod_time":`...)
+	buf = strconv.AppendInt(buf, d.ModTime, 10)
+	buf = append(buf, `,"is_dir":true,"file_count":`...)
+	buf = strconv.AppendInt(buf, d.FileCount, 10)
+	buf = append(buf, `,"dir_count":`...)
+	buf = strconv.AppendInt(buf, d.DirCount, 10)
+	return append(buf, '}', '\n')
+}
+
+// rollupSizes 扫描完成后自底向上汇总文件树中的目录大小（构建了文件树时使用，供 PrintTree 显示）
+// 目录节点的 Size/DiskUsage 为所有子项的合计（磁盘占用按硬链接去重，与总计口径一致），
+// FileCount/DirCount 为递归的文件数和子目录数
+func (s *Scanner) rollupSizes() {
+	RollupTree(s.root)
+}
+
+// RollupTree 递归汇总单个目录节点
+func RollupTree(node *FileNode) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	var size, disk, files, dirs int64
+	for _, child := range node.Children {
+		if child.IsDir {
+			RollupTree(child)
+			dirs += child.DirCount + 1
+			files += child.FileCount
+			size += child.Size
+			disk += child.DiskUsage
+			continue
+		}
+		files++
+		size += child.Size
+		// 重复的硬链接已经在首次出现的位置计算过磁盘占用
+		if !child.IsHardlink {
+			disk += child.DiskUsage
+		}
+	}
+	node.Size = size
+	node.DiskUsage = disk
+	node.FileCount = files
+	node.DirCount = dirs
+}
@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// scanRoot 一个扫描根目录，多个根目录共享 worker、inode 去重和输出文件
+type scanRoot struct {
+	path     string
+	real     string       // 解析符号链接后的真实路径（判断符号链接是否指向扫描范围之外）
+	dev      uint64       // 所在设备号（-xdev 时只扫描这个设备）
+	modTime  int64        // 根目录的修改时间（恢复扫描时根目录不会重新扫描）
+	node     *FileNode    // 根目录节点
+	excluded bool         // 根目录本身位于排除路径之下
+	globs    *ignoreRules // -exclude-glob 规则（相对这个根目录）
+	total    RootTotal    // 合计（outputMu 保护）
+}
+
+// RootTotal 一个扫描根目录的合计（扫描多个根目录时写入统计）
+type RootTotal struct {
+	Path      string `json:"path"`
+	Dirs      int64  `json:"dirs"`
+	Files     int64  `json:"files"`
+	TotalSize int64  `json:"total_size"`
+	TotalDisk int64  `json:"total_disk"`
+	Errors    int64  `json:"errors"`
+}
+
+// resolveRoots 将根目录转换为绝对路径并检查是否为目录，相同或位于另一个根目录之下的根目录合并到上层的根目录
+// 按解析符号链接后的真实路径判断（如 macOS 上的 /tmp 位于 /private 之下），返回保留的根目录（保持原顺序）和合并说明
+func resolveRoots(paths []string) (roots []string, merged []string, err error) {
+	type candidate struct {
+		path, real string
+	}
+	candidates := make([]candidate, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("路径错误: %v", err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无法访问路径 %s: %v", abs, err)
+		}
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("%s 不是一个目录", abs)
+		}
+		real := abs
+		if r, err := filepath.EvalSymlinks(abs); err == nil {
+			real = r
+		}
+		candidates = append(candidates, candidate{abs, real})
+	}
+
+	for i, c := range candidates {
+		kept := true
+		for j, o := range candidates {
+			if i == j || !IsPathWithin(c.real, o.real) {
+				continue
+			}
+			// 相同的根目录只保留第一个
+			if c.real == o.real && i < j {
+				continue
+			}
+			if c.real == o.real {
+				merged = append(merged, fmt.Sprintf("%s 与 %s 相同", c.path, o.path))
+			} else {
+				merged = append(merged, fmt.Sprintf("%s 位于 %s 之下", c.path, o.path))
+			}
+			kept = false
+			break
+		}
+		if kept {
+			roots = append(roots, c.path)
+		}
+	}
+	return roots, merged, nil
+}
+
+// rootOf 返回路径所在的根目录（根目录互不包含，最多只有一个匹配）
+func (s *Scanner) rootOf(path string) *scanRoot {
+	if len(s.roots) > 1 {
+		for _, r := range s.roots {
+			if IsPathWithin(path, r.path) {
+				return r
+			}
+		}
+	}
+	return s.roots[0]
+}
+
+// rootAt 返回以 path 为根目录的 scanRoot，path 不是根目录时返回 nil
+func (s *Scanner) rootAt(path string) *scanRoot {
+	for _, r := range s.roots {
+		if r.path == path {
+			return r
+		}
+	}
+	return nil
+}
+
+// withinRoots 判断真实路径是否位于某个根目录之下
+func (s *Scanner) withinRoots(real string) bool {
+	for _, r := range s.roots {
+		if IsPathWithin(real, r.real) {
+			return true
+		}
+	}
+	return false
+}
+
+// multiRoots 扫描多个根目录时返回全部根目录，只有一个时返回 nil（写入文件头）
+func (s *Scanner) multiRoots() []string {
+	if len(s.roots) < 2 {
+		return nil
+	}
+	return s.options.RootPaths
+}
+
+// commitRoot 累加目录批次到所在根目录的合计（需持有 outputMu，只在扫描多个根目录时统计）
+func (s *Scanner) commitRoot(b *dirBatch) {
+	t := &b.root.total
+	t.Dirs += b.dirs
+	t.Files += b.files
+	t.TotalSize += b.size
+	t.TotalDisk += b.disk
+	t.Errors += b.errors
+}
+
+// rootTotals 各根目录的合计，只有一个根目录时返回 nil（需持有 outputMu）
+func (s *Scanner) rootTotals() []RootTotal {
+	if len(s.roots) < 2 {
+		return nil
+	}
+	totals := make([]RootTotal, len(s.roots))
+	for i, r := range s.roots {
+		totals[i] = r.total
+		totals[i].Path = r.path
+	}
+	return totals
+}
+
+// restoreRoots 恢复扫描时从检查点恢复各根目录的合计
+func (s *Scanner) restoreRoots(totals []RootTotal) {
+	for _, t := range totals {
+		if r := s.rootAt(t.Path); r != nil {
+			r.total = t
+		}
+	}
+}
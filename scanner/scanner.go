@@ -0,0 +1,1515 @@
+// Package scanner 并发扫描目录树，将文件/目录记录写入 JSON Lines 输出文件，
+// 并通过 Sink 报告条目、进度和错误，供命令行工具和 GUI 直接嵌入使用
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// FileNode 表示文件树中的一个节点
+type FileNode struct {
+	Path        string      `json:"path"`
+	Name        string      `json:"name"`
+	Size        int64       `json:"size"`       // 逻辑大小（文件声称的大小；目录为子项合计）
+	DiskUsage   int64       `json:"disk_usage"` // 实际磁盘占用（块数 * 512；目录为子项合计，硬链接只计一次）
+	ModTime     int64       `json:"mod_time"`   // 修改时间（Unix timestamp）
+	IsDir       bool        `json:"is_dir"`
+	IsSparse    bool        `json:"is_sparse,omitempty"`    // 是否为稀疏文件
+	IsHardlink  bool        `json:"is_hardlink,omitempty"`  // 是否为硬链接（重复的inode）
+	Hash        string      `json:"hash,omitempty"`         // 内容哈希（带算法前缀，开启 -hash 时计算）
+	HashSampled bool        `json:"hash_sampled,omitempty"` // 哈希是否为抽样计算（超过大小上限的文件）
+	FileCount   int64       `json:"file_count,omitempty"`   // 目录下的文件总数（递归，扫描完成后汇总）
+	DirCount    int64       `json:"dir_count,omitempty"`    // 目录下的子目录总数（递归，扫描完成后汇总）
+	LinkTarget  string      `json:"link_target,omitempty"`  // 跟随的符号链接解析后的目标路径
+	InodeKey    string      `json:"inode_key,omitempty"`    // 参与硬链接去重的文件的 "dev:ino"（增量扫描沿用记录时据此重新去重）
+	*FileMeta               // 扩展元数据（开启 -meta 时记录）
+	Root        string      `json:"root,omitempty"` // 所在的扫描根目录（只在扫描多个根目录时记录）
+	Children    []*FileNode `json:"children,omitempty"`
+	mu          sync.RWMutex
+}
+
+// Options 扫描选项
+type Options struct {
+	RootPath           string   // 扫描根目录（扫描多个根目录时为第一个）
+	RootPaths          []string // 多个扫描根目录，设置后代替 RootPath；相同或相互包含的根目录只扫描最上层的一个
+	MinSize            int64
+	MaxSize            int64
+	WorkerCount        int
+	Order              string         // 目录遍历顺序：dfs（深度优先，默认）或 bfs（广度优先）
+	MaxOpenDirs        int            // 同时打开的目录句柄上限，0 表示不额外限制（每个 worker 同时最多打开一个目录）
+	ErrorsFile         string         // 错误文件路径，每个错误写入一条 JSON 记录（errno 分类、路径、所在目录）
+	OutputFile         string         // 输出文件路径，"-" 表示标准输出（不支持检查点、排序和恢复扫描）
+	Format             string         // 输出格式：jsonl（默认）、csv、tsv、null 或 template，非 jsonl 格式不写入目录汇总和检查点
+	Template           string         // template 格式的记录模板，如 "{size}\t{path}\n"
+	Verbose            bool           // 通过 Sink.Notice 报告跳过的挂载点、断开的符号链接等细节
+	ExcludePaths       []string       // 要排除的路径列表
+	ExcludeGlobs       []string       // 要排除的 glob 模式（gitignore 语法，相对扫描根目录，如 **/node_modules）
+	IgnoreFiles        bool           // 遵循扫描中遇到的 .gitignore/.ignore/.mfsignore
+	FollowSymlinks     bool           // 跟随符号链接（目录按 dev:ino 去重，避免循环）
+	OneFileSystem      bool           // 不进入与扫描根目录不在同一设备上的目录（挂载点）
+	MaxDepth           int            // 最大扫描深度（根目录的子项为第 1 层），0 表示不限制
+	OnlyFsTypes        []string       // 只进入这些文件系统类型的挂载点（如 apfs,hfs）
+	SkipFsTypes        []string       // 跳过这些文件系统类型的挂载点（如 nfs,smbfs,fuse）
+	IncludeExts        []string       // 包含的文件扩展名列表 (如: .txt, .log)
+	ExcludeExts        []string       // 排除的文件扩展名列表
+	NamePattern        string         // 文件名正则表达式模式
+	Meta               bool           // 记录属主、权限、inode、链接数和访问/变化/创建时间，并按用户汇总
+	Users              []string       // 只包含这些用户的文件（用户名或 uid）
+	Groups             []string       // 只包含这些用户组的文件（组名或 gid）
+	Perm               string         // 权限条件（find 语法：644 完全相同，-644 包含全部位，/644 包含任意一位）
+	Newer              string         // 只包含时间晚于此的文件（[mtime|atime|ctime|btime:]30d 或 2006-01-02）
+	Older              string         // 只包含时间早于此的文件，格式同 Newer
+	SinceOutput        string         // 上一次扫描的输出文件，用于增量扫描
+	Resume             bool           // 从输出文件对应的检查点恢复扫描
+	CheckpointInterval time.Duration  // 检查点保存间隔，0 表示不保存
+	FindDupes          bool           // 收集文件用于查找重复文件
+	HashAlgo           string         // 内容哈希算法（sha256/xxhash/blake），为空表示不计算
+	HashMaxSize        int64          // 计算哈希的文件大小上限，0 表示不限制
+	HashLarge          string         // 超过大小上限的文件：skip 跳过，sample 抽样计算
+	HashWorkers        int            // 计算哈希的并发数
+	SortedOutput       bool           // 扫描完成后将输出文件按路径排序
+	DirSummary         bool           // 扫描完成后为每个目录写入汇总记录
+	BuildTree          bool           // 在内存中构建完整的文件树（GetFileTree 需要），否则记录写出后即丢弃
+	TopN               int            // 收集最大的 N 个文件/目录和最旧的 N 个大文件，0 表示不收集
+	TopOldMinSize      int64          // "最旧的大文件" 的大小下限
+	Nice               bool           // 低负载模式：系统负载高时自动减少 worker（不调整进程优先级，整个进程的优先级由调用方决定）
+	MaxIOPS            int            // ReadDir/Lstat 调用的速率上限（次/秒），0 表示不限制
+	MaxEntriesPerSec   int            // 处理目录项的速率上限（个/秒），0 表示不限制
+	Sink               Sink           // 接收条目、进度、错误和提示信息，nil 表示不关心
+	nameRegex          *regexp.Regexp // 编译后的正则表达式（内部使用）
+}
+
+// Scanner 文件扫描器
+type Scanner struct {
+	options        Options
+	root           *FileNode
+	queue          *dirScheduler  // 待扫描目录的调度器
+	taskWg         sync.WaitGroup // 任务计数
+	workerWg       sync.WaitGroup // worker 计数
+	nodeMap        sync.Map       // 用于快速查找父节点
+	inodeMap       sync.Map       // 跟踪已处理的 inode (key: "dev:ino")
+	dirInodeMap    sync.Map       // 跟踪已扫描的目录 inode，避免重复扫描（firmlinks等）
+	fileCount      atomic.Int64
+	dirCount       atomic.Int64
+	symlinkCount   atomic.Int64 // 符号链接计数
+	sparseCount    atomic.Int64 // 稀疏文件计数
+	hardlinkCount  atomic.Int64 // 硬链接计数（重复的 inode）
+	dupDirCount    atomic.Int64 // 重复目录计数（firmlinks等）
+	reusedDirCount atomic.Int64 // 增量扫描中沿用上次结果的目录计数
+	hashErrorCount atomic.Int64 // 计算哈希失败的文件数
+	excludedCount  atomic.Int64 // 排除的目录计数
+	skippedMounts  atomic.Int64 // 按 -xdev/-fstype 跳过的挂载点计数
+	depthLimited   atomic.Int64 // 达到 -max-depth 未进入的目录计数
+	errorCount     atomic.Int64
+	totalSize      atomic.Int64           // 文件逻辑大小总和
+	totalDisk      atomic.Int64           // 实际磁盘占用总和（去重后）
+	currentDir     atomic.Pointer[string] // 最近开始扫描的目录（报告进度时使用）
+	diskUsedSize   int64                  // 磁盘已使用空间大小
+	roots          []*scanRoot            // 扫描根目录（互不包含）
+	mergedRoots    []string               // 被合并到其他根目录的根目录说明
+	links          SymlinkReport          // 断开的和指向扫描根目录之外的符号链接（跟随符号链接时收集，outputMu 保护）
+	filters        *metaFilters           // 按元数据筛选文件的条件
+	names          idNames                // uid/gid 名称缓存
+	owners         map[uint32]*OwnerTotal // 各用户拥有的文件合计（开启 Meta 时收集，outputMu 保护）
+	throttle       *throttle              // IO 限速和 worker 数自动调整（低负载模式时使用）
+	mounts         *mountTracker          // 经过的挂载点及各自的合计
+	outputFile     *os.File               // 输出文件句柄
+	output         *bufio.Writer          // 输出文件的写缓冲（outputMu 保护），每次报告进度时落盘
+	compression    string                 // 输出文件的压缩格式（按扩展名），为空表示不压缩
+	encoder        compressWriter         // 当前的压缩段，不压缩时为 nil（outputMu 保护）
+	outputMu       sync.Mutex             // 输出文件锁
+	encode         recordEncoder          // 按输出格式编码记录
+	tableHeader    []byte                 // CSV/TSV 的表头行
+	prev           *prevScan              // 上一次扫描结果（增量扫描时使用）
+	checkpoint     *checkpointer          // 检查点状态（输出到文件时使用）
+	dupes          *dupeFinder            // 重复文件查找（开启 FindDupes 时使用）
+	hashes         *hashPool              // 内容哈希 worker 池（开启 HashAlgo 时使用）
+	top            *topCollector          // 最大文件/目录排行（开启 TopN 时使用）
+	agg            *dirAggregator         // 目录大小流式汇总（输出目录汇总或排行时使用）
+	errors         *errorLog              // 按 errno 和目录分组的错误统计
+	summaries      *summarySpool          // 暂存的目录汇总记录
+	sink           Sink                   // 条目、进度、错误和提示信息的接收者（串行调用）
+	ctx            context.Context        // 扫描的 context，取消后不再进入新的目录
+	excludeSet     map[string]struct{}    // 排除路径集合
+	queuedRules    sync.Map               // 已入队目录 -> 上级目录生效的忽略规则（只保存非空规则）
+}
+
+// New 创建扫描器，选项有误时返回错误
+func New(options Options) (*Scanner, error) {
+	if len(options.RootPaths) == 0 {
+		options.RootPaths = []string{options.RootPath}
+	}
+	roots, merged, err := resolveRoots(options.RootPaths)
+	if err != nil {
+		return nil, err
+	}
+	options.RootPaths = roots
+	options.RootPath = roots[0]
+
+	if options.WorkerCount <= 0 {
+		options.WorkerCount = runtime.NumCPU() * 4
+	}
+	if options.HashWorkers <= 0 {
+		options.HashWorkers = 4
+	}
+	if options.Order == "" {
+		options.Order = OrderDFS
+	}
+	if options.Order != OrderDFS && options.Order != OrderBFS {
+		return nil, fmt.Errorf("遍历顺序只支持 %s 或 %s", OrderDFS, OrderBFS)
+	}
+	if options.MaxOpenDirs < 0 {
+		options.MaxOpenDirs = 0
+	}
+
+	// 编译正则表达式（如果提供）
+	if options.NamePattern != "" {
+		regex, err := regexp.Compile(options.NamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式编译失败: %v", err)
+		}
+		options.nameRegex = regex
+	}
+
+	// 验证哈希参数
+	if options.HashAlgo != "" {
+		if _, err := newHasher(options.HashAlgo); err != nil {
+			return nil, err
+		}
+		if options.HashLarge == "" {
+			options.HashLarge = HashLargeSkip
+		}
+		if options.HashLarge != HashLargeSkip && options.HashLarge != HashLargeSample {
+			return nil, fmt.Errorf("HashLarge 只支持 %s 或 %s", HashLargeSkip, HashLargeSample)
+		}
+	}
+
+	// 输出格式：只有 JSON Lines 可以被再次读取，排序、恢复扫描和目录汇总都依赖它
+	if options.Format == "" {
+		options.Format = FormatJSONL
+	}
+	encode, tableHeader, err := newRecordEncoder(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Format != FormatJSONL {
+		if options.SortedOutput || options.Resume {
+			return nil, fmt.Errorf("排序输出和恢复扫描只支持 %s 格式", FormatJSONL)
+		}
+		options.DirSummary = false
+		options.CheckpointInterval = 0
+	}
+	if options.OutputFile == "-" {
+		if options.SortedOutput || options.Resume {
+			return nil, fmt.Errorf("输出到标准输出时不能排序输出或恢复扫描")
+		}
+		options.CheckpointInterval = 0
+	}
+
+	var sink Sink = SinkFuncs{}
+	if options.Sink != nil {
+		sink = &lockedSink{sink: options.Sink}
+	}
+
+	s := &Scanner{
+		options:     options,
+		sink:        sink,
+		ctx:         context.Background(),
+		queue:       newDirScheduler(options.WorkerCount, options.Order, options.MaxOpenDirs),
+		encode:      encode,
+		errors:      newErrorLog(),
+		tableHeader: tableHeader,
+		mergedRoots: merged,
+	}
+
+	// 排除路径：子项在进入目录前逐个检查，被排除的目录不会进入，所以只需精确匹配
+	s.excludeSet = make(map[string]struct{}, len(options.ExcludePaths))
+	for _, p := range options.ExcludePaths {
+		s.excludeSet[p] = struct{}{}
+	}
+	for _, path := range roots {
+		r := &scanRoot{
+			path: path,
+			node: &FileNode{
+				Path:     path,
+				Name:     filepath.Base(path),
+				IsDir:    true,
+				Children: make([]*FileNode, 0),
+			},
+		}
+		for _, p := range options.ExcludePaths {
+			if IsPathWithin(path, p) {
+				r.excluded = true
+			}
+		}
+		if r.globs, err = newIgnoreRules(path, options.ExcludeGlobs); err != nil {
+			return nil, fmt.Errorf("排除模式错误: %v", err)
+		}
+		s.roots = append(s.roots, r)
+	}
+	// 文件树的根节点：多个根目录时挂在一个虚拟节点下
+	s.root = s.roots[0].node
+	if len(s.roots) > 1 {
+		s.root = &FileNode{Name: fmt.Sprintf("%d 个根目录", len(s.roots)), IsDir: true}
+		for _, r := range s.roots {
+			s.root.Children = append(s.root.Children, r.node)
+		}
+	}
+	filters, err := compileMetaFilters(&options, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.filters = filters
+	s.owners = make(map[uint32]*OwnerTotal)
+	s.throttle = newThrottle(options)
+	if options.FindDupes {
+		s.dupes = newDupeFinder()
+	}
+	if options.TopN > 0 {
+		s.top = newTopCollector(options.RootPaths, options.TopN, options.TopOldMinSize)
+	}
+	if options.TopN > 0 || (options.DirSummary && options.OutputFile != "") {
+		s.agg = newDirAggregator(options.RootPaths, s.dirDone)
+	}
+	return s, nil
+}
+
+// shouldIncludeFile 判断文件是否符合大小筛选条件
+func (s *Scanner) shouldIncludeFile(size int64) bool {
+	if s.options.MinSize > 0 && size < s.options.MinSize {
+		return false
+	}
+	if s.options.MaxSize > 0 && size > s.options.MaxSize {
+		return false
+	}
+	return true
+}
+
+// shouldExcludePath 判断路径是否应该被排除
+// rules 为所在目录生效的忽略文件规则；排除路径和 -exclude-glob 总是生效，不能被忽略文件中的 ! 规则重新包含
+func (s *Scanner) shouldExcludePath(path string, isDir bool, rules *ignoreRules) bool {
+	// 检查用户指定的排除列表
+	// 排除 /Volumes/Data 时 /Volumes/Data/subdir 不会被访问到，因此精确匹配即可
+	root := s.rootOf(path)
+	if _, ok := s.excludeSet[path]; ok || root.excluded {
+		return true
+	}
+	if root.globs.match(path, isDir) {
+		return true
+	}
+	return rules.match(path, isDir)
+}
+
+// shouldIncludeFileByExt 判断文件扩展名是否符合过滤条件
+func (s *Scanner) shouldIncludeFileByExt(filename string) bool {
+	// 如果没有配置扩展名过滤，则包含所有文件
+	if len(s.options.IncludeExts) == 0 && len(s.options.ExcludeExts) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	// 如果配置了排除列表，检查是否在排除列表中
+	if len(s.options.ExcludeExts) > 0 {
+		for _, excludeExt := range s.options.ExcludeExts {
+			if ext == strings.ToLower(excludeExt) {
+				return false
+			}
+		}
+	}
+
+	// 如果配置了包含列表，只包含列表中的扩展名
+	if len(s.options.IncludeExts) > 0 {
+		for _, includeExt := range s.options.IncludeExts {
+			if ext == strings.ToLower(includeExt) {
+				return true
+			}
+		}
+		return false // 不在包含列表中
+	}
+
+	return true
+}
+
+// shouldIncludeFileByName 判断文件名是否符合正则表达式模式
+func (s *Scanner) shouldIncludeFileByName(filename string) bool {
+	// 如果没有配置正则表达式，则包含所有文件
+	if s.options.nameRegex == nil {
+		return true
+	}
+
+	return s.options.nameRegex.MatchString(filename)
+}
+
+// worker 工作协程，处理目录扫描
+func (s *Scanner) worker(id int) {
+	defer s.workerWg.Done()
+
+	// 任务在目录提交时完成（见 commitBatch），提交可能因等待哈希而晚于 scanDirectory 返回
+	for {
+		dirPath, ok := s.queue.next(id)
+		if !ok {
+			return
+		}
+		// 扫描已取消：不再进入目录，直接完成任务让队列尽快排空（检查点中该目录仍为待扫描）
+		if s.ctx.Err() != nil {
+			s.taskWg.Done()
+			continue
+		}
+		s.throttle.acquire()
+		s.scanDirectory(id, dirPath)
+		s.throttle.release()
+	}
+}
+
+// scanDirectory 扫描单个目录，发现的子目录放入 worker 的本地队列
+func (s *Scanner) scanDirectory(worker int, dirPath string) {
+	// 目录的所有输出在扫描结束时一次性提交（无论成功与否，都要标记该目录已完成）
+	b := newDirBatch(dirPath)
+	b.worker = worker
+	s.currentDir.Store(&dirPath)
+	b.root = s.rootOf(dirPath)
+	if len(s.roots) > 1 {
+		b.recordRoot = b.root.path
+	}
+	defer s.releaseBatch(b)
+
+	// 上级目录生效的忽略规则（入队时保存）
+	if v, ok := s.queuedRules.LoadAndDelete(dirPath); ok {
+		b.rules = v.(*ignoreRules)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.errorCount.Add(1)
+			s.reportError(&ScanError{Op: "panic in", Path: dirPath, IsDir: true, Err: fmt.Errorf("%v", r)})
+		}
+	}()
+
+	// 先验证路径是否仍然存在且是目录（避免竞态条件）
+	s.throttle.io()
+	info, err := s.statPath(dirPath)
+	if err != nil {
+		// 文件/目录可能在扫描过程中被删除，这是正常的
+		b.errors++
+		s.reportBatchError(b, &ScanError{Op: "路径不存在或无法访问", Path: dirPath, IsDir: true, Err: err})
+		return
+	}
+
+	// 确保是目录而不是文件（避免竞态条件导致类型变化）
+	if !info.IsDir() {
+		// 可能在加入队列后从目录变成了文件，跳过即可
+		if s.options.Verbose {
+			s.sink.Notice(fmt.Sprintf("⚠️  路径不再是目录 %s", dirPath))
+		}
+		return
+	}
+	b.modTime = info.ModTime().Unix()
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok {
+		b.dev = uint64(stat.Dev)
+		b.mount = s.mounts.lookup(b.dev, dirPath)
+	}
+
+	// 检查目录是否已经扫描过（通过 dev:ino 去重，避免 firmlinks/硬链接/符号链接循环等重复扫描）
+	// 注意：根目录总是需要扫描，只登记不检查（指回根目录的符号链接会因此被去重）
+	if ok {
+		dirInodeKey := fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+		_, exists := s.dirInodeMap.LoadOrStore(dirInodeKey, true)
+		if exists && s.rootAt(dirPath) == nil {
+			// 这个目录已经扫描过（可能是 firmlink 或其他方式的重复访问）
+			// 静默跳过，这是正常的内部处理
+			b.dupDirs++
+			return
+		}
+		if !exists {
+			b.addKey(dirKeyPrefix, dirInodeKey)
+		}
+	}
+
+	// 获取当前目录节点（只在构建文件树时需要，否则记录写出后即丢弃）
+	var parentNode *FileNode
+	if s.options.BuildTree {
+		parentNode = s.getOrCreateNode(dirPath)
+		if parentNode == nil {
+			b.errors++
+			s.reportBatchError(b, &ScanError{Op: "无法创建节点", Path: dirPath, IsDir: true})
+			return
+		}
+	}
+
+	// 增量扫描：目录修改时间未变化时沿用上次的子项记录，跳过 ReadDir 和逐个 Lstat
+	if s.prev != nil {
+		if children, ok := s.prev.unchangedChildren(dirPath, info); ok {
+			if s.options.IgnoreFiles {
+				b.rules = s.loadIgnoreRules(b, dirPath, b.rules, nil)
+			}
+			s.reuseDirectory(b, parentNode, children)
+			return
+		}
+	}
+
+	s.throttle.io()
+	s.queue.openDir()
+	entries, err := os.ReadDir(dirPath)
+	s.queue.closeDir()
+	if err != nil {
+		// 对于 bad file descriptor（EBADF）等预期的系统错误，完全忽略（不计数、不显示）
+		// 这通常发生在 /dev/fd 等动态变化的虚拟目录中
+		// 只对非预期错误计数和报告
+		if !errors.Is(err, syscall.EBADF) {
+			b.errors++
+			s.reportBatchError(b, &ScanError{Op: "无法读取目录", Path: dirPath, IsDir: true, Err: err})
+		}
+		return
+	}
+
+	// 本目录的忽略文件作用于本目录及其子目录
+	if s.options.IgnoreFiles {
+		b.rules = s.loadIgnoreRules(b, dirPath, b.rules, entries)
+	}
+
+	for _, entry := range entries {
+		s.throttle.entry()
+		fullPath := filepath.Join(dirPath, entry.Name())
+
+		// 优先检查是否应该排除此路径（在获取文件信息之前，节省系统调用）
+		if s.shouldExcludePath(fullPath, entry.IsDir(), b.rules) {
+			b.excluded++
+			continue
+		}
+
+		// 获取文件信息（不跟随符号链接）
+		s.throttle.io()
+		info, err := os.Lstat(fullPath)
+		if err != nil {
+			// 对于 bad file descriptor（EBADF）等预期的系统错误，完全忽略
+			if !errors.Is(err, syscall.EBADF) {
+				b.errors++
+				s.reportBatchError(b, &ScanError{Op: "无法获取文件信息", Path: fullPath, Err: err})
+			}
+			continue
+		}
+
+		// 默认跳过符号链接，避免循环引用和重复计算；跟随时改用链接目标的信息
+		var linkTarget string
+		if info.Mode()&os.ModeSymlink != 0 {
+			b.symlinks++
+			if !s.options.FollowSymlinks {
+				continue
+			}
+			target, resolved, ok := s.followSymlink(b, fullPath)
+			if !ok {
+				continue
+			}
+			// 排除检查时还不知道链接指向目录，只匹配目录的规则需要再检查一次
+			if target.IsDir() && s.shouldExcludePath(fullPath, true, b.rules) {
+				b.excluded++
+				continue
+			}
+			info, linkTarget = target, resolved
+		}
+
+		// 跳过特殊文件（设备文件、socket等）
+		if !info.Mode().IsRegular() && !info.Mode().IsDir() {
+			continue
+		}
+
+		if info.IsDir() {
+			if s.skipMountPoint(b, fullPath, info) {
+				continue
+			}
+			s.addDirNode(b, parentNode, fullPath, entry.Name(), info, linkTarget)
+			continue
+		}
+
+		// 处理文件
+		size := info.Size()
+
+		// 检查文件大小和扩展名过滤条件
+		if !s.shouldIncludeFile(size) {
+			continue
+		}
+
+		if !s.shouldIncludeFileByExt(entry.Name()) {
+			continue
+		}
+
+		if !s.shouldIncludeFileByName(entry.Name()) {
+			continue
+		}
+
+		meta, ok := s.matchMeta(fullPath, info)
+		if !ok {
+			continue
+		}
+
+		// 获取实际磁盘占用
+		var diskUsage int64
+		var isSparse bool
+		var isHardlink bool
+		var inodeKey string
+
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if ok {
+			// Blocks 是 512 字节块的数量
+			diskUsage = stat.Blocks * 512
+
+			// 如果实际占用小于逻辑大小的 95%，认为是稀疏文件
+			if size > 0 && float64(diskUsage) < float64(size)*0.95 {
+				isSparse = true
+				b.sparse++
+			}
+
+			// 检查是否为硬链接（通过 dev:ino 去重）
+			// 只对硬链接数 > 1 的文件进行去重检查；跟随符号链接时同一个文件可能经由链接重复出现，所有文件都要检查
+			if stat.Nlink > 1 || s.options.FollowSymlinks {
+				inodeKey = fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+				isHardlink = s.dedupeInode(b, inodeKey)
+			}
+		} else {
+			// 无法获取块信息，使用逻辑大小
+			diskUsage = size
+		}
+
+		s.addFileNode(b, parentNode, &FileNode{
+			Path:       fullPath,
+			Name:       entry.Name(),
+			Size:       size,
+			DiskUsage:  diskUsage,
+			ModTime:    info.ModTime().Unix(), // 添加修改时间
+			IsSparse:   isSparse,
+			IsHardlink: isHardlink,
+			IsDir:      false,
+			LinkTarget: linkTarget,
+			InodeKey:   inodeKey,
+			FileMeta:   meta,
+		})
+	}
+}
+
+// reuseDirectory 沿用上次扫描记录的子项（增量扫描，目录未变化时调用）
+// 子文件直接使用上次的记录；子目录重新获取修改时间后照常入队，由其自身判断是否变化
+func (s *Scanner) reuseDirectory(b *dirBatch, parentNode *FileNode, children []*ScanRecord) {
+	b.reusedDirs++
+
+	for _, rec := range children {
+		s.throttle.entry()
+		if s.shouldExcludePath(rec.Path, rec.IsDir, b.rules) {
+			b.excluded++
+			continue
+		}
+
+		if rec.IsDir {
+			s.throttle.io()
+			info, err := s.statPath(rec.Path)
+			if err != nil || !info.IsDir() {
+				// 目录未变化时子项不应消失，出现这种情况说明扫描期间发生了变化
+				b.errors++
+				s.reportBatchError(b, &ScanError{Op: "增量扫描时子目录不可用", Path: rec.Path, IsDir: true, Err: err})
+				continue
+			}
+			if s.skipMountPoint(b, rec.Path, info) {
+				continue
+			}
+			s.addDirNode(b, parentNode, rec.Path, rec.Name, info, rec.LinkTarget)
+			continue
+		}
+
+		// 使用当前的过滤条件，避免两次扫描参数不同时混入不符合条件的文件
+		if !s.shouldIncludeFile(rec.Size) || !s.shouldIncludeFileByExt(rec.Name) || !s.shouldIncludeFileByName(rec.Name) {
+			continue
+		}
+
+		if rec.IsSparse {
+			b.sparse++
+		}
+		// 同一个 inode 的其他名字可能位于重新读取的目录中，沿用的记录也要参与去重
+		isHardlink := rec.IsHardlink
+		if inodeKey := s.reusedInodeKey(rec); inodeKey != "" {
+			isHardlink = s.dedupeInode(b, inodeKey)
+		} else if isHardlink {
+			b.hardlinks++
+		}
+
+		node := &FileNode{
+			Path:       rec.Path,
+			Name:       rec.Name,
+			Size:       rec.Size,
+			DiskUsage:  rec.DiskUsage,
+			ModTime:    rec.ModTime,
+			IsSparse:   rec.IsSparse,
+			IsHardlink: isHardlink,
+			LinkTarget: rec.LinkTarget,
+			InodeKey:   rec.InodeKey,
+		}
+		// 元数据：上次记录了就沿用，否则重新获取
+		if s.options.Meta || s.filters.active {
+			meta := rec.FileMeta
+			if meta == nil {
+				info, err := s.statPath(rec.Path)
+				if err != nil {
+					b.errors++
+					s.reportBatchError(b, &ScanError{Op: "无法获取文件信息", Path: rec.Path, Err: err})
+					continue
+				}
+				meta = s.fileMeta(rec.Path, info)
+			}
+			if s.filters.active && (meta == nil || !s.filters.match(meta, rec.ModTime)) {
+				continue
+			}
+			if s.options.Meta {
+				node.FileMeta = meta
+			}
+		}
+		// 同一算法的哈希可以沿用，否则重新计算
+		if s.options.HashAlgo != "" && strings.HasPrefix(rec.Hash, hashPrefix(s.options.HashAlgo)) {
+			node.Hash = rec.Hash
+			node.HashSampled = rec.HashSampled
+		}
+		s.addFileNode(b, parentNode, node)
+	}
+}
+
+// dedupeInode 按 dev:ino 登记文件，同一个 inode 已经出现过时返回 true（重复的硬链接，已经计算过磁盘占用）
+func (s *Scanner) dedupeInode(b *dirBatch, inodeKey string) bool {
+	if _, exists := s.inodeMap.LoadOrStore(inodeKey, true); exists {
+		b.hardlinks++
+		return true
+	}
+	b.addKey(hardlinkKeyPrefix, inodeKey)
+	return false
+}
+
+// reusedInodeKey 沿用的文件记录的 dev:ino，不需要去重时返回空字符串
+// 旧版本的输出文件没有 inode_key，只对已知有多个链接的文件重新获取
+func (s *Scanner) reusedInodeKey(rec *ScanRecord) string {
+	if rec.InodeKey != "" {
+		return rec.InodeKey
+	}
+	if !rec.IsHardlink && (rec.FileMeta == nil || rec.Nlink <= 1) {
+		return ""
+	}
+	s.throttle.io()
+	info, err := s.statPath(rec.Path)
+	if err != nil {
+		return ""
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+}
+
+// addDirNode 添加子目录节点，记录在提交时写入，子目录在提交后加入扫描队列
+// linkTarget 为跟随的符号链接解析后的目标路径（不是链接时为空）
+func (s *Scanner) addDirNode(b *dirBatch, parentNode *FileNode, fullPath, name string, info os.FileInfo, linkTarget string) {
+	// 创建子目录节点（记录修改时间，供下次增量扫描判断目录是否变化）
+	childNode := &FileNode{
+		Path:       fullPath,
+		Name:       name,
+		ModTime:    info.ModTime().Unix(),
+		IsDir:      true,
+		LinkTarget: linkTarget,
+	}
+	if s.options.Meta {
+		childNode.FileMeta = s.fileMeta(fullPath, info)
+	}
+
+	// 添加到父节点并存储节点映射（构建文件树时）
+	if parentNode != nil {
+		childNode.Children = make([]*FileNode, 0)
+		parentNode.mu.Lock()
+		parentNode.Children = append(parentNode.Children, childNode)
+		parentNode.mu.Unlock()
+		s.nodeMap.Store(fullPath, childNode)
+	}
+	b.dirs++
+
+	// 写入目录信息（如果设置了文件大小筛选，则排除目录）
+	if s.options.MinSize == 0 && s.options.MaxSize == 0 {
+		b.addRecord(childNode)
+	}
+
+	// 达到最大深度的目录只记录自身，不再进入
+	if s.options.MaxDepth > 0 && s.depth(fullPath) >= s.options.MaxDepth {
+		b.depthLimited++
+		return
+	}
+	b.subdirs = append(b.subdirs, fullPath)
+}
+
+// depth 返回路径相对扫描根目录的层级（根目录为 0）
+func (s *Scanner) depth(path string) int {
+	rel := strings.Trim(strings.TrimPrefix(path, s.rootOf(path).path), "/")
+	if rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// addFileNode 添加文件节点，累加统计并写入记录
+func (s *Scanner) addFileNode(b *dirBatch, parentNode *FileNode, fileNode *FileNode) {
+	if parentNode != nil {
+		parentNode.mu.Lock()
+		parentNode.Children = append(parentNode.Children, fileNode)
+		parentNode.mu.Unlock()
+	}
+
+	b.files++
+	b.size += fileNode.Size
+
+	// 只在首次遇到 inode 时累加磁盘占用
+	if !fileNode.IsHardlink {
+		b.disk += fileNode.DiskUsage
+	}
+
+	if s.dupes != nil {
+		s.dupes.add(fileNode)
+	}
+
+	b.addRecord(fileNode)
+
+	// 提交给哈希 worker 池，记录在哈希完成后随目录一起写入
+	if s.hashes != nil && fileNode.Hash == "" {
+		s.hashes.submit(b, fileNode)
+	}
+}
+
+// dirBatch 单个目录扫描产生的全部输出
+// 记录写入、统计累加和子目录入队在目录扫描结束时于同一把锁内一次性提交，
+// 保证检查点看到的输出文件、待扫描目录和统计数据始终一致
+type dirBatch struct {
+	dirPath    string
+	worker     int          // 扫描该目录的 worker，子目录放入它的本地队列
+	root       *scanRoot    // 目录所在的根目录
+	recordRoot string       // 记录的 root 字段（只在扫描多个根目录时设置）
+	modTime    int64        // 目录自身的修改时间
+	dev        uint64       // 目录所在的设备号
+	mount      *MountTotal  // 目录所在的挂载点
+	rules      *ignoreRules // 本目录生效的忽略规则（传给子目录）
+	pending    atomic.Int32 // 未完成的工作数（目录扫描本身 + 等待中的哈希任务）
+	hashErrors atomic.Int64 // 计算哈希失败的文件数（多个哈希 worker 同时累加）
+	records    []*FileNode  // 待写入的记录
+	subdirs    []string     // 待入队的子目录
+	keys       []string     // 新登记的 inode key（恢复扫描时重建去重状态）
+
+	files, dirs, size, disk     int64
+	sparse, hardlinks, symlinks int64
+	dupDirs, excluded, errors   int64
+	reusedDirs                  int64
+	skippedMounts, depthLimited int64
+
+	brokenLinks, escapingLinks []SymlinkInfo // 跟随符号链接时发现的断开链接和指向扫描根目录之外的链接
+
+	errMu     sync.Mutex     // 扫描目录的 worker 和哈希 worker 都会报告错误
+	errorRecs []*ErrorRecord // 待提交的错误记录（errMu 保护）
+}
+
+// newDirBatch 创建目录的提交批次，初始的一个待完成工作是目录扫描本身
+func newDirBatch(dirPath string) *dirBatch {
+	b := &dirBatch{dirPath: dirPath}
+	b.pending.Store(1)
+	return b
+}
+
+// addRecord 追加一条文件/目录记录
+func (b *dirBatch) addRecord(node *FileNode) {
+	node.Root = b.recordRoot
+	b.records = append(b.records, node)
+}
+
+// addKey 登记一个 inode key
+func (b *dirBatch) addKey(prefix, key string) {
+	b.keys = append(b.keys, prefix+key)
+}
+
+// addError 登记一个待提交的错误
+func (b *dirBatch) addError(rec *ErrorRecord) {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	b.errorRecs = append(b.errorRecs, rec)
+}
+
+// releaseBatch 完成批次中的一项工作，全部完成后提交
+func (s *Scanner) releaseBatch(b *dirBatch) {
+	if b.pending.Add(-1) == 0 {
+		s.commitBatch(b)
+	}
+}
+
+// commitBatch 提交目录扫描结果：写入记录、累加统计、更新检查点状态并将子目录入队
+func (s *Scanner) commitBatch(b *dirBatch) {
+	// 在锁外编码记录，锁内只复制到写缓冲
+	var buf *[]byte
+	if s.outputFile != nil && len(b.records) > 0 {
+		buf = recordBufPool.Get().(*[]byte)
+		for _, node := range b.records {
+			*buf = s.encode(*buf, node)
+		}
+	}
+
+	s.outputMu.Lock()
+	if buf != nil {
+		if _, err := s.output.Write(*buf); err != nil {
+			s.reportError(&ScanError{Op: "写入文件失败", Path: s.options.OutputFile, Err: err})
+		}
+		*buf = (*buf)[:0]
+		recordBufPool.Put(buf)
+	}
+
+	s.fileCount.Add(b.files)
+	s.dirCount.Add(b.dirs)
+	s.totalSize.Add(b.size)
+	s.totalDisk.Add(b.disk)
+	s.sparseCount.Add(b.sparse)
+	s.hardlinkCount.Add(b.hardlinks)
+	s.symlinkCount.Add(b.symlinks)
+	s.dupDirCount.Add(b.dupDirs)
+	s.excludedCount.Add(b.excluded)
+	s.errorCount.Add(b.errors)
+	s.hashErrorCount.Add(b.hashErrors.Load())
+	if len(b.errorRecs) > 0 {
+		s.commitErrors(b.errorRecs)
+	}
+	s.reusedDirCount.Add(b.reusedDirs)
+	s.skippedMounts.Add(b.skippedMounts)
+	s.depthLimited.Add(b.depthLimited)
+	s.mounts.commit(b)
+	if len(s.roots) > 1 {
+		s.commitRoot(b)
+	}
+	s.links.Broken = append(s.links.Broken, b.brokenLinks...)
+	s.links.Escaping = append(s.links.Escaping, b.escapingLinks...)
+	if s.options.Meta {
+		s.commitOwners(b)
+	}
+
+	if s.checkpoint != nil {
+		s.checkpoint.commit(b)
+	}
+	for _, node := range b.records {
+		s.sink.Entry(node)
+	}
+	s.outputMu.Unlock()
+
+	if s.top != nil {
+		s.top.commit(b)
+	}
+	// 目录汇总依赖子目录在入队之前登记
+	if s.agg != nil {
+		s.agg.commit(b)
+	}
+
+	// 将子目录加入队列
+	for _, path := range b.subdirs {
+		if b.rules != nil {
+			s.queuedRules.Store(path, b.rules)
+		}
+		s.taskWg.Add(1)
+		s.queue.push(b.worker, path)
+	}
+
+	// 本目录的任务完成（必须在子目录计入任务之后）
+	s.taskWg.Done()
+}
+
+// recordBufPool 编码记录用的缓冲区
+var recordBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 64*1024)
+		return &buf
+	},
+}
+
+// setOutput 设置输出文件并创建写缓冲，输出文件以 .gz/.zst 结尾时经过压缩流写入
+func (s *Scanner) setOutput(f *os.File) error {
+	s.outputFile = f
+	s.compression = compressionFor(s.options.OutputFile)
+	return s.startSegment()
+}
+
+// startSegment 开始新的压缩段（gzip 成员或 zstd 帧），追加在文件当前位置
+func (s *Scanner) startSegment() error {
+	enc, err := newCompressWriter(s.outputFile, s.compression)
+	if err != nil {
+		return fmt.Errorf("无法创建压缩流: %v", err)
+	}
+	s.encoder = enc
+	var w io.Writer = s.outputFile
+	if enc != nil {
+		w = enc
+	}
+	if s.output == nil {
+		s.output = bufio.NewWriterSize(w, 256*1024)
+	} else {
+		s.output.Reset(w)
+	}
+	return nil
+}
+
+// flushOutput 将写缓冲中的记录写入输出文件，压缩输出同时产生一个刷新点（需持有 outputMu）
+func (s *Scanner) flushOutput() error {
+	if s.output == nil {
+		return nil
+	}
+	err := s.output.Flush()
+	if err == nil && s.encoder != nil {
+		err = s.encoder.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	return nil
+}
+
+// endSegment 写入缓冲中的记录并结束当前的压缩段，文件内容成为完整的压缩流（需持有 outputMu）
+// 检查点位置、排序和扫描结束记录都要求文件停在压缩段的边界上
+func (s *Scanner) endSegment() error {
+	if err := s.flushOutput(); err != nil {
+		return err
+	}
+	if s.encoder == nil {
+		return nil
+	}
+	err := s.encoder.Close()
+	s.encoder = nil
+	if err != nil {
+		return fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	return nil
+}
+
+// closeOutput 写入缓冲中剩余的记录并关闭输出文件（标准输出不关闭）
+func (s *Scanner) closeOutput() {
+	s.outputMu.Lock()
+	s.endSegment()
+	s.outputMu.Unlock()
+	if s.outputFile != os.Stdout {
+		s.outputFile.Close()
+	}
+}
+
+// appendFileRecord 将一条文件/目录记录编码为一行 JSON 追加到 buf
+func appendFileRecord(buf []byte, node *FileNode) []byte {
+	buf = append(buf, '{')
+	buf = appendPathField(buf, "path", node.Path)
+	buf = append(buf, `,"name":`...)
+	buf = appendJSONString(buf, node.Name)
+	if node.Root != "" {
+		buf = append(buf, `,"root":`...)
+		buf = appendJSONString(buf, node.Root)
+	}
+	buf = append(buf, `,"size":`...)
+	buf = strconv.AppendInt(buf, node.Size, 10)
+	buf = append(buf, `,"disk_usage":`...)
+	buf = strconv.AppendInt(buf, node.DiskUsage, 10)
+	buf = append(buf, `,"mod_time":`...)
+	buf = strconv.AppendInt(buf, node.ModTime, 10)
+	buf = append(buf, `,"is_dir":`...)
+	buf = strconv.AppendBool(buf, node.IsDir)
+	if node.IsHardlink {
+		buf = append(buf, `,"is_hardlink":true`...)
+	} else if node.IsSparse {
+		buf = append(buf, `,"is_sparse":true`...)
+	}
+	if node.InodeKey != "" {
+		buf = append(buf, `,"inode_key":`...)
+		buf = appendJSONString(buf, node.InodeKey)
+	}
+	if node.Hash != "" {
+		buf = append(buf, `,"hash":`...)
+		buf = appendJSONString(buf, node.Hash)
+		if node.HashSampled {
+			buf = append(buf, `,"hash_sampled":true`...)
+		}
+	}
+	if node.LinkTarget != "" {
+		buf = append(buf, ',')
+		buf = appendPathField(buf, "link_target", node.LinkTarget)
+	}
+	if node.FileMeta != nil {
+		buf = appendMeta(buf, node.FileMeta)
+	}
+	return append(buf, '}', '\n')
+}
+
+// getOrCreateNode 获取或创建节点
+func (s *Scanner) getOrCreateNode(path string) *FileNode {
+	if r := s.rootAt(path); r != nil {
+		return r.node
+	}
+
+	if node, ok := s.nodeMap.Load(path); ok {
+		return node.(*FileNode)
+	}
+
+	return nil
+}
+
+// Scan 按选项扫描目录，等同于 New 之后调用 Scanner.Scan
+// 需要文件树、排行、重复文件等扫描后的结果时改用 New 创建扫描器
+func Scan(ctx context.Context, options Options) (*Stats, error) {
+	s, err := New(options)
+	if err != nil {
+		return nil, err
+	}
+	return s.Scan(ctx)
+}
+
+// Options 返回补全默认值后的扫描选项（如绝对路径的 RootPath、默认的 WorkerCount）
+func (s *Scanner) Options() Options {
+	return s.options
+}
+
+// Scan 开始扫描，扫描器只能使用一次
+// ctx 取消后不再进入新的目录，正在扫描的目录完成后返回已有的统计和 ctx.Err()；
+// 输出到文件且开启了检查点时会保存检查点，之后可以用 Resume 继续
+func (s *Scanner) Scan(ctx context.Context) (*Stats, error) {
+	s.ctx = ctx
+
+	// 加载上一次扫描结果（必须在创建输出文件之前，两者可能是同一个文件）
+	if s.options.SinceOutput != "" {
+		prev, err := loadPrevScan(s.options.SinceOutput)
+		if err != nil {
+			return nil, fmt.Errorf("无法加载增量扫描基准: %v", err)
+		}
+		s.prev = prev
+		s.sink.Notice(fmt.Sprintf("🔁 增量扫描基准: %s (%s 条记录, %s 个目录)",
+			s.options.SinceOutput, FormatNumber(prev.recordCount), FormatNumber(int64(len(prev.dirModTimes)))))
+		if prev.badLines > 0 {
+			s.sink.Notice(fmt.Sprintf("⚠️  基准文件中有 %d 行无法解析，已忽略", prev.badLines))
+		}
+		if len(prev.dirModTimes) == 0 {
+			s.sink.Notice("⚠️  基准文件中没有目录记录（可能使用了 -min/-max），将执行完整扫描")
+		}
+	}
+
+	for _, msg := range s.mergedRoots {
+		s.sink.Notice(fmt.Sprintf("🔀 %s，已合并，不会重复扫描", msg))
+	}
+	for _, r := range s.roots {
+		// 根目录的修改时间（恢复扫描时根目录不会重新扫描）和所在设备
+		if info, err := os.Lstat(r.path); err == nil {
+			r.modTime = info.ModTime().Unix()
+			r.node.ModTime = r.modTime
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				r.dev = uint64(stat.Dev)
+			}
+		}
+		// 判断符号链接是否指向扫描根目录之外时使用真实路径（如 macOS 的 /tmp 实际是 /private/tmp）
+		r.real = r.path
+		if real, err := filepath.EvalSymlinks(r.path); err == nil {
+			r.real = real
+		}
+	}
+
+	// 挂载表（必须在恢复扫描之前读取）
+	mounts, err := newMountTracker()
+	if err != nil && (len(s.options.OnlyFsTypes) > 0 || len(s.options.SkipFsTypes) > 0) {
+		s.sink.Notice(fmt.Sprintf("⚠️  %v，无法按文件系统类型过滤", err))
+	}
+	s.mounts = mounts
+
+	// 目录汇总记录先暂存，扫描结束后追加到输出文件（必须在恢复扫描重放记录之前创建）
+	if s.options.DirSummary && s.options.OutputFile != "" {
+		spool, err := newSummarySpool(s.options.OutputFile)
+		if err != nil {
+			return nil, err
+		}
+		s.summaries = spool
+		defer spool.close()
+	}
+
+	// 错误文件（恢复扫描时从检查点位置继续写入）
+	defer s.errors.close()
+	if s.options.ErrorsFile != "" && !s.options.Resume {
+		if err := s.errors.create(s.options.ErrorsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// 待扫描的目录：新扫描从根目录开始，恢复扫描从检查点记录的目录继续
+	pending := append([]string(nil), s.options.RootPaths...)
+
+	// 打开输出文件
+	if s.options.Resume {
+		if s.options.OutputFile == "" {
+			return nil, fmt.Errorf("恢复扫描需要指定输出文件")
+		}
+		if s.options.FindDupes {
+			// 检查点之前扫描到的文件不在内存中，无法参与查找
+			return nil, fmt.Errorf("查找重复文件不能与恢复扫描同时使用")
+		}
+		resumed, err := s.resumeFromCheckpoint()
+		if err != nil {
+			return nil, err
+		}
+		pending = resumed
+		defer s.closeOutput()
+	} else if s.options.OutputFile != "" {
+		f := os.Stdout
+		if s.options.OutputFile != "-" {
+			if f, err = os.Create(s.options.OutputFile); err != nil {
+				return nil, fmt.Errorf("无法创建输出文件: %v", err)
+			}
+		}
+		if err := s.setOutput(f); err != nil {
+			s.closeOutput()
+			return nil, err
+		}
+		defer s.closeOutput()
+
+		// 写入文件头（每行都是 JSON 对象，严格的 JSON Lines 解析器也能读取）；CSV/TSV 写入表头行
+		if s.options.Format == FormatJSONL {
+			err = writeRecord(s.output, ScanHeader{
+				Type:      recordTypeHeader,
+				Format:    outputFormat,
+				Version:   outputFormatVersion,
+				RootPath:  s.options.RootPath,
+				Roots:     s.multiRoots(),
+				StartTime: time.Now().Format(time.RFC3339),
+			})
+		} else {
+			_, err = s.output.Write(s.tableHeader)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("无法写入输出文件: %v", err)
+		}
+
+		if s.options.CheckpointInterval > 0 {
+			if err := s.newCheckpointer(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 磁盘已使用空间，用于估算进度（多个根目录在同一设备上时只计一次）
+	seenDevs := make(map[uint64]bool, len(s.roots))
+	for _, r := range s.roots {
+		if seenDevs[r.dev] {
+			continue
+		}
+		seenDevs[r.dev] = true
+		if _, used, _, err := DiskUsage(r.path); err == nil {
+			s.diskUsedSize += used
+		}
+	}
+
+	startTime := time.Now()
+
+	// 存储根节点
+	for _, r := range s.roots {
+		s.nodeMap.Store(r.path, r.node)
+	}
+
+	// 启动哈希 worker 池（必须在扫描 worker 之前）
+	if s.options.HashAlgo != "" {
+		s.hashes = newHashPool(s, s.options.HashWorkers)
+	}
+
+	// 启动工作协程
+	for i := 0; i < s.options.WorkerCount; i++ {
+		s.workerWg.Add(1)
+		go s.worker(i)
+	}
+
+	// 后台协程在 done 关闭后退出，扫描器等它们全部退出后才继续，之后不会再有进度报告或检查点写入
+	done := make(chan bool)
+	var background sync.WaitGroup
+	runBackground := func(fn func(done chan bool)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			fn(done)
+		}()
+	}
+
+	// 定期报告进度
+	runBackground(s.reportProgress)
+
+	// 按系统负载调整 worker 数
+	if s.throttle != nil && s.throttle.gate != nil {
+		runBackground(s.throttle.adapt)
+	}
+
+	// 定期保存检查点，供中断后恢复
+	if s.checkpoint != nil && s.options.CheckpointInterval > 0 {
+		runBackground(s.runCheckpoints)
+	}
+
+	// 添加待扫描目录到队列
+	s.taskWg.Add(len(pending))
+	go func() {
+		ruleCache := make(map[string]*ignoreRules)
+		for _, dirPath := range pending {
+			if s.options.BuildTree {
+				s.ensureNode(dirPath)
+			}
+			// 恢复扫描时上级目录不会重新扫描，需要重新读取上级目录的忽略文件
+			if s.options.IgnoreFiles {
+				if rules := s.ancestorIgnoreRules(dirPath, ruleCache); rules != nil {
+					s.queuedRules.Store(dirPath, rules)
+				}
+			}
+			s.queue.push(-1, dirPath)
+		}
+	}()
+
+	// 等待所有任务完成
+	s.taskWg.Wait()
+	s.queue.close()
+
+	// 等待所有 worker 退出
+	s.workerWg.Wait()
+	if s.hashes != nil {
+		s.hashes.close()
+	}
+	close(done)
+	background.Wait()
+
+	if s.options.BuildTree {
+		s.rollupSizes()
+	}
+
+	// 已取消：保存检查点供恢复，目录汇总和排序留到扫描完整结束时进行
+	// 结束记录写在检查点位置之后，恢复扫描时会被截掉
+	if ctx.Err() != nil {
+		stats := s.stats(time.Since(startTime))
+		if s.checkpoint != nil {
+			if err := s.saveCheckpoint(); err != nil {
+				s.writeSummary(stats, err)
+				return stats, err
+			}
+			s.checkpoint.keysFile.Close()
+		}
+		s.writeSummary(stats, context.Cause(ctx))
+		return stats, ctx.Err()
+	}
+
+	// 追加目录汇总记录，写入后检查点才失效（中断时恢复扫描会重新生成）
+	if s.summaries != nil {
+		s.sink.Progress(s.progress(PhaseSummarizing, time.Since(startTime)))
+		s.outputMu.Lock()
+		err := s.summaries.appendTo(s.output)
+		s.outputMu.Unlock()
+		if err != nil {
+			s.writeSummary(s.stats(time.Since(startTime)), err)
+			return nil, err
+		}
+	}
+
+	// 扫描完整结束，检查点不再需要
+	if s.checkpoint != nil {
+		s.removeCheckpoint()
+	}
+
+	// 记录按 goroutine 完成顺序写入，需要确定顺序时在扫描结束后统一排序
+	if s.options.SortedOutput && s.outputFile != nil {
+		s.sink.Notice("🔤 正在按路径排序输出文件...")
+		s.sink.Progress(s.progress(PhaseSorting, time.Since(startTime)))
+		s.outputMu.Lock()
+		err := s.endSegment()
+		s.outputMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if err := sortOutputFile(s.options.OutputFile); err != nil {
+			return nil, fmt.Errorf("输出文件排序失败: %v", err)
+		}
+		// 排序替换了输出文件，扫描结束记录追加到新文件末尾
+		f, err := os.OpenFile(s.options.OutputFile, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("无法打开输出文件: %v", err)
+		}
+		s.outputMu.Lock()
+		s.outputFile.Close()
+		err = s.setOutput(f)
+		s.outputMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stats := s.stats(time.Since(startTime))
+	if err := s.writeSummary(stats, nil); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// writeSummary 在输出文件末尾追加扫描结束记录，cause 为 nil 表示扫描完整结束，否则为中断原因
+func (s *Scanner) writeSummary(stats *Stats, cause error) error {
+	if s.outputFile == nil || s.options.Format != FormatJSONL {
+		return nil
+	}
+	summary := ScanSummary{
+		Type:     recordTypeSummary,
+		Complete: cause == nil,
+		Reason:   "completed",
+		EndTime:  time.Now().Format(time.RFC3339),
+		Duration: stats.Duration.Seconds(),
+		Stats:    stats,
+	}
+	if cause != nil {
+		summary.Reason = cause.Error()
+	}
+
+	// 结束记录写入当前的压缩段，之后结束压缩段，文件内容成为完整的压缩流
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+	if err := writeRecord(s.output, summary); err != nil {
+		return fmt.Errorf("无法写入扫描结束记录: %v", err)
+	}
+	return s.endSegment()
+}
+
+// stats 汇总扫描统计
+func (s *Scanner) stats(duration time.Duration) *Stats {
+	st := &Stats{
+		Duration:      duration,
+		Dirs:          s.dirCount.Load(),
+		Files:         s.fileCount.Load(),
+		TotalSize:     s.totalSize.Load(),
+		TotalDisk:     s.totalDisk.Load(),
+		Symlinks:      s.symlinkCount.Load(),
+		Sparse:        s.sparseCount.Load(),
+		Hardlinks:     s.hardlinkCount.Load(),
+		DupDirs:       s.dupDirCount.Load(),
+		ReusedDirs:    s.reusedDirCount.Load(),
+		HashErrors:    s.hashErrorCount.Load(),
+		Excluded:      s.excludedCount.Load(),
+		SkippedMounts: s.skippedMounts.Load(),
+		DepthLimited:  s.depthLimited.Load(),
+		Errors:        s.errorCount.Load(),
+		ErrorsByErrno: s.errors.counts(),
+	}
+	s.outputMu.Lock()
+	st.BrokenLinks = int64(len(s.links.Broken))
+	st.EscapingLinks = int64(len(s.links.Escaping))
+	st.Roots = s.rootTotals()
+	s.outputMu.Unlock()
+	if s.throttle != nil && s.throttle.gate != nil {
+		st.MinWorkers = int(s.throttle.minimum.Load())
+	}
+	return st
+}
+
+// reportProgress 定期向 Sink 报告扫描进度
+func (s *Scanner) reportProgress(done chan bool) {
+	const interval = 500 * time.Millisecond // 每0.5秒更新一次，更流畅
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	startTime := time.Now()
+	lastDirs := int64(0)
+	lastFiles := int64(0)
+	lastDisk := int64(0)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p := s.progress(PhaseScanning, time.Since(startTime))
+
+			// 计算速度
+			p.DirSpeed = float64(p.DirCount-lastDirs) / interval.Seconds()
+			p.FileSpeed = float64(p.FileCount-lastFiles) / interval.Seconds()
+			p.DiskSpeed = float64(p.TotalDisk-lastDisk) / interval.Seconds()
+			lastDirs, lastFiles, lastDisk = p.DirCount, p.FileCount, p.TotalDisk
+			s.sink.Progress(p)
+
+			// 写缓冲中的记录落盘，扫描中途查看输出文件时最多落后一个周期
+			if s.output != nil {
+				s.outputMu.Lock()
+				if err := s.flushOutput(); err != nil {
+					s.reportError(&ScanError{Op: "写入文件失败", Path: s.options.OutputFile, Err: err})
+				}
+				s.outputMu.Unlock()
+			}
+		}
+	}
+}
+
+// progress 当前的扫描进度（不含速度）
+func (s *Scanner) progress(phase string, elapsed time.Duration) Progress {
+	p := Progress{
+		Phase:         phase,
+		Elapsed:       elapsed.Seconds(),
+		DirCount:      s.dirCount.Load(),
+		FileCount:     s.fileCount.Load(),
+		TotalDisk:     s.totalDisk.Load(),
+		DiskUsedSize:  s.diskUsedSize,
+		QueueDepth:    s.queue.queued.Load(),
+		ErrorCount:    s.errorCount.Load(),
+		ErrorsByErrno: s.errors.counts(),
+	}
+	if phase == PhaseScanning {
+		if dir := s.currentDir.Load(); dir != nil {
+			p.CurrentDir = *dir
+		}
+	}
+
+	// 按已使用空间估算进度，扫描过程中最多99.9%，只有完成时才是100%
+	// 剩余时间按目前的平均速度估算
+	if s.diskUsedSize > 0 && p.TotalDisk > 0 {
+		p.Percentage = float64(p.TotalDisk) / float64(s.diskUsedSize) * 100
+		if p.Percentage > 99.9 {
+			p.Percentage = 99.9
+		}
+		if phase == PhaseScanning {
+			p.ETA = p.Elapsed * (100 - p.Percentage) / p.Percentage
+		}
+	}
+	if s.throttle != nil && s.throttle.gate != nil {
+		p.Workers = int(s.throttle.limit.Load())
+	}
+	return p
+}
+
+// Counts 返回当前已扫描的目录数和文件数（扫描进行中也可以调用）
+func (s *Scanner) Counts() (dirs, files int64) {
+	return s.dirCount.Load(), s.fileCount.Load()
+}
+
+// GetFileTree 获取文件树（需要开启 BuildTree，否则只有根节点）
+func (s *Scanner) GetFileTree() *FileNode {
+	return s.root
+}
+
+// ChildNodes 返回子节点的副本（扫描进行中也可以安全调用）
+func (n *FileNode) ChildNodes() []*FileNode {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return append([]*FileNode(nil), n.Children...)
+}
+
+// DiskUsage 获取路径所在磁盘的总空间、已使用空间和剩余空间
+func DiskUsage(path string) (total, used, free int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0, err
+	}
+	// 总空间 = 总块数 * 块大小，已使用空间 = (总块数 - 空闲块数) * 块大小
+	total = int64(stat.Blocks) * int64(stat.Bsize)
+	used = (int64(stat.Blocks) - int64(stat.Bfree)) * int64(stat.Bsize)
+	free = int64(stat.Bfree) * int64(stat.Bsize)
+	return total, used, free, nil
+}
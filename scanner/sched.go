@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// 待扫描目录的遍历顺序
+const (
+	OrderDFS = "dfs" // 深度优先（默认）：worker 先处理自己最近发现的子目录，待扫描的目录和未完成的目录汇总较少
+	OrderBFS = "bfs" // 广度优先：先处理较浅的目录
+)
+
+// localQueueSize 每个 worker 本地队列的容量，超出的目录放入全局溢出列表
+const localQueueSize = 256
+
+// dirDeque worker 的本地双端队列（环形缓冲区）：所有者按遍历顺序从一端取，其他 worker 从最早放入的一端窃取
+type dirDeque struct {
+	mu    sync.Mutex
+	buf   [localQueueSize]string
+	head  int // 最早放入的目录所在的位置
+	count int
+}
+
+// push 放入一个目录，队列已满时返回 false
+func (d *dirDeque) push(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count == len(d.buf) {
+		return false
+	}
+	d.buf[(d.head+d.count)%len(d.buf)] = path
+	d.count++
+	return true
+}
+
+// popFront 取最早放入的目录
+func (d *dirDeque) popFront() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count == 0 {
+		return "", false
+	}
+	path := d.buf[d.head]
+	d.buf[d.head] = ""
+	d.head = (d.head + 1) % len(d.buf)
+	d.count--
+	return path, true
+}
+
+// popBack 取最近放入的目录
+func (d *dirDeque) popBack() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count == 0 {
+		return "", false
+	}
+	i := (d.head + d.count - 1) % len(d.buf)
+	path := d.buf[i]
+	d.buf[i] = ""
+	d.count--
+	return path, true
+}
+
+// dirScheduler 待扫描目录的调度器
+// 子目录放入发现它的 worker 的本地队列，本地队列满了或不是由 worker 放入时进入全局溢出列表；
+// 空闲的 worker 依次取本地队列、全局溢出列表，最后从其他 worker 的队列中窃取最早放入的目录（通常更浅，子树更大）
+// 待扫描的目录只占用路径字符串，不再为每个子目录创建一个阻塞在通道上的 goroutine
+type dirScheduler struct {
+	order    string
+	locals   []*dirDeque
+	openDirs chan struct{} // 同时打开的目录句柄上限，nil 表示不限制
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	overflow []string     // 全局溢出列表（mu 保护）
+	closed   bool         // 所有任务已完成，worker 可以退出（mu 保护）
+	queued   atomic.Int64 // 所有队列中的目录数
+	idle     atomic.Int32 // 等待中的 worker 数
+}
+
+// newDirScheduler 为 workers 个 worker 创建调度器，maxOpenDirs <= 0 表示不限制同时打开的目录数
+func newDirScheduler(workers int, order string, maxOpenDirs int) *dirScheduler {
+	q := &dirScheduler{order: order, locals: make([]*dirDeque, workers)}
+	for i := range q.locals {
+		q.locals[i] = &dirDeque{}
+	}
+	if maxOpenDirs > 0 {
+		q.openDirs = make(chan struct{}, maxOpenDirs)
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 放入一个待扫描目录，worker < 0 表示不是由 worker 放入（直接进入全局溢出列表）
+func (q *dirScheduler) push(worker int, path string) {
+	// 先计数再放入：等待中的 worker 看到计数后会重新查找，不会错过
+	q.queued.Add(1)
+	if worker < 0 || !q.locals[worker].push(path) {
+		q.mu.Lock()
+		q.overflow = append(q.overflow, path)
+		q.mu.Unlock()
+	}
+	if q.idle.Load() > 0 {
+		q.mu.Lock()
+		q.cond.Signal()
+		q.mu.Unlock()
+	}
+}
+
+// next 取 worker 的下一个待扫描目录，没有时等待，调度器关闭后返回 false
+func (q *dirScheduler) next(worker int) (string, bool) {
+	for {
+		if path, ok := q.take(worker); ok {
+			q.queued.Add(-1)
+			return path, true
+		}
+		q.mu.Lock()
+		q.idle.Add(1)
+		for q.queued.Load() == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		q.idle.Add(-1)
+		done := q.closed && q.queued.Load() == 0
+		q.mu.Unlock()
+		if done {
+			return "", false
+		}
+	}
+}
+
+// take 依次从本地队列、全局溢出列表和其他 worker 的队列中取一个目录
+func (q *dirScheduler) take(worker int) (string, bool) {
+	local := q.locals[worker]
+	if q.order == OrderBFS {
+		if path, ok := local.popFront(); ok {
+			return path, true
+		}
+	} else if path, ok := local.popBack(); ok {
+		return path, true
+	}
+
+	q.mu.Lock()
+	if n := len(q.overflow); n > 0 {
+		var path string
+		if q.order == OrderBFS {
+			path = q.overflow[0]
+			q.overflow[0] = ""
+			q.overflow = q.overflow[1:]
+		} else {
+			path = q.overflow[n-1]
+			q.overflow = q.overflow[:n-1]
+		}
+		q.mu.Unlock()
+		return path, true
+	}
+	q.mu.Unlock()
+
+	for i := 1; i < len(q.locals); i++ {
+		if path, ok := q.locals[(worker+i)%len(q.locals)].popFront(); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// close 所有任务完成后调用，等待中的 worker 退出
+func (q *dirScheduler) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// openDir 在打开目录之前调用，达到同时打开的目录数上限时等待
+func (q *dirScheduler) openDir() {
+	if q.openDirs != nil {
+		q.openDirs <- struct{}{}
+	}
+}
+
+// closeDir 在目录读取完毕（句柄已关闭）后调用
+func (q *dirScheduler) closeDir() {
+	if q.openDirs != nil {
+		<-q.openDirs
+	}
+}
@@ -0,0 +1,292 @@
+package scanner
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDirDeque(t *testing.T) {
+	var d dirDeque
+	if _, ok := d.popBack(); ok {
+		t.Fatal("空队列 popBack 应返回 false")
+	}
+	if _, ok := d.popFront(); ok {
+		t.Fatal("空队列 popFront 应返回 false")
+	}
+
+	for _, p := range []string{"a", "b", "c", "d"} {
+		if !d.push(p) {
+			t.Fatalf("push(%s) 失败", p)
+		}
+	}
+	// popFront 取最早放入的，popBack 取最近放入的
+	steps := []struct {
+		front bool
+		want  string
+	}{
+		{true, "a"}, {false, "d"}, {true, "b"}, {false, "c"},
+	}
+	for _, s := range steps {
+		var got string
+		var ok bool
+		if s.front {
+			got, ok = d.popFront()
+		} else {
+			got, ok = d.popBack()
+		}
+		if !ok || got != s.want {
+			t.Errorf("pop(front=%v) = %q, %v，应为 %q", s.front, got, ok, s.want)
+		}
+	}
+	if d.count != 0 {
+		t.Errorf("count = %d，应为 0", d.count)
+	}
+}
+
+func TestDirDequeFullAndWrap(t *testing.T) {
+	var d dirDeque
+	for i := 0; i < localQueueSize; i++ {
+		if !d.push(fmt.Sprint(i)) {
+			t.Fatalf("第 %d 个 push 失败", i)
+		}
+	}
+	if d.push("overflow") {
+		t.Fatal("队列已满时 push 应返回 false")
+	}
+
+	// 从头部取出一半再放入，环形缓冲区绕回后顺序不变
+	half := localQueueSize / 2
+	for i := 0; i < half; i++ {
+		if got, _ := d.popFront(); got != fmt.Sprint(i) {
+			t.Fatalf("popFront = %q，应为 %d", got, i)
+		}
+	}
+	for i := localQueueSize; i < localQueueSize+half; i++ {
+		if !d.push(fmt.Sprint(i)) {
+			t.Fatalf("绕回后 push(%d) 失败", i)
+		}
+	}
+	for i := half; i < localQueueSize+half; i++ {
+		if got, ok := d.popFront(); !ok || got != fmt.Sprint(i) {
+			t.Fatalf("popFront = %q, %v，应为 %d", got, ok, i)
+		}
+	}
+	if _, ok := d.popBack(); ok {
+		t.Fatal("全部取出后 popBack 应返回 false")
+	}
+}
+
+func TestDirSchedulerOrder(t *testing.T) {
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{OrderDFS, []string{"c", "b", "a"}},
+		{OrderBFS, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		// 本地队列
+		q := newDirScheduler(2, tt.order, 0)
+		for _, p := range []string{"a", "b", "c"} {
+			q.push(0, p)
+		}
+		for _, want := range tt.want {
+			if got, ok := q.take(0); !ok || got != want {
+				t.Errorf("%s 本地队列: take = %q，应为 %q", tt.order, got, want)
+			}
+		}
+
+		// 不是由 worker 放入的目录进入全局溢出列表，顺序相同
+		q = newDirScheduler(2, tt.order, 0)
+		for _, p := range []string{"a", "b", "c"} {
+			q.push(-1, p)
+		}
+		for _, want := range tt.want {
+			if got, ok := q.take(1); !ok || got != want {
+				t.Errorf("%s 溢出列表: take = %q，应为 %q", tt.order, got, want)
+			}
+		}
+		if _, ok := q.take(0); ok {
+			t.Errorf("%s: 全部取出后 take 应返回 false", tt.order)
+		}
+	}
+}
+
+func TestDirSchedulerPriority(t *testing.T) {
+	q := newDirScheduler(3, OrderDFS, 0)
+	q.push(-1, "overflow")
+	q.push(1, "w1-old")
+	q.push(1, "w1-new")
+	q.push(0, "w0")
+
+	// 先取本地队列，再取溢出列表，最后从其他 worker 窃取最早放入的目录
+	for _, want := range []string{"w0", "overflow", "w1-old", "w1-new"} {
+		if got, ok := q.take(0); !ok || got != want {
+			t.Errorf("take = %q, %v，应为 %q", got, ok, want)
+		}
+	}
+	if _, ok := q.take(2); ok {
+		t.Error("全部取出后 take 应返回 false")
+	}
+}
+
+func TestDirSchedulerLocalOverflow(t *testing.T) {
+	q := newDirScheduler(1, OrderBFS, 0)
+	for i := 0; i < localQueueSize+10; i++ {
+		q.push(0, fmt.Sprint(i))
+	}
+	if n := len(q.overflow); n != 10 {
+		t.Errorf("本地队列满后溢出 %d 个，应为 10", n)
+	}
+	if n := q.queued.Load(); n != int64(localQueueSize+10) {
+		t.Errorf("queued = %d，应为 %d", n, localQueueSize+10)
+	}
+	seen := make(map[string]bool)
+	for {
+		p, ok := q.take(0)
+		if !ok {
+			break
+		}
+		seen[p] = true
+	}
+	if len(seen) != localQueueSize+10 {
+		t.Errorf("取出 %d 个不同的目录，应为 %d", len(seen), localQueueSize+10)
+	}
+}
+
+func TestDirSchedulerNextWaitsAndCloses(t *testing.T) {
+	q := newDirScheduler(2, OrderDFS, 0)
+	got := make(chan string, 1)
+	go func() {
+		p, ok := q.next(1)
+		if !ok {
+			p = "<closed>"
+		}
+		got <- p
+	}()
+
+	select {
+	case p := <-got:
+		t.Fatalf("没有目录时 next 不应返回，实际返回 %q", p)
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.push(0, "a")
+	select {
+	case p := <-got:
+		if p != "a" {
+			t.Errorf("next = %q，应为 a（从 worker 0 窃取）", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("放入目录后等待中的 worker 没有被唤醒")
+	}
+
+	done := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func(worker int) {
+			_, ok := q.next(worker)
+			done <- ok
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+	for i := 0; i < 2; i++ {
+		select {
+		case ok := <-done:
+			if ok {
+				t.Error("关闭后 next 应返回 false")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("关闭后等待中的 worker 没有退出")
+		}
+	}
+}
+
+// 多个 worker 同时放入和取出（模拟扫描：处理一个目录时发现子目录），每个目录恰好被取出一次
+func TestDirSchedulerConcurrent(t *testing.T) {
+	for _, order := range []string{OrderDFS, OrderBFS} {
+		const workers, fanout, depth = 8, 3, 6
+		q := newDirScheduler(workers, order, 0)
+
+		var pending sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[string]int)
+		var total atomic.Int64
+
+		pending.Add(1)
+		q.push(-1, "r")
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for {
+					p, ok := q.next(worker)
+					if !ok {
+						return
+					}
+					mu.Lock()
+					seen[p]++
+					mu.Unlock()
+					total.Add(1)
+					if len(p) < 2*depth {
+						for i := 0; i < fanout; i++ {
+							pending.Add(1)
+							q.push(worker, fmt.Sprintf("%s/%d", p, i))
+						}
+					}
+					pending.Done()
+				}
+			}(w)
+		}
+		pending.Wait()
+		q.close()
+		wg.Wait()
+
+		// 1 + 3 + 9 + ... + 3^depth
+		want, level := 0, 1
+		for i := 0; i <= depth; i++ {
+			want += level
+			level *= fanout
+		}
+		if int(total.Load()) != want || len(seen) != want {
+			t.Errorf("%s: 取出 %d 次、%d 个不同的目录，应为 %d", order, total.Load(), len(seen), want)
+		}
+		for p, n := range seen {
+			if n != 1 {
+				t.Errorf("%s: %s 被取出 %d 次", order, p, n)
+			}
+		}
+		if n := q.queued.Load(); n != 0 {
+			t.Errorf("%s: 结束后 queued = %d", order, n)
+		}
+	}
+}
+
+func TestDirSchedulerOpenDirs(t *testing.T) {
+	// 不限制时立即返回
+	newDirScheduler(1, OrderDFS, 0).openDir()
+
+	q := newDirScheduler(2, OrderDFS, 2)
+	q.openDir()
+	q.openDir()
+	acquired := make(chan struct{})
+	go func() {
+		q.openDir()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("达到上限时 openDir 应等待")
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.closeDir()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("closeDir 之后等待中的 openDir 没有返回")
+	}
+}
@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// Sink 接收扫描过程中产生的条目、进度、错误和提示信息
+// 所有方法都由扫描器串行调用，实现不需要自行加锁，但应尽快返回，否则会拖慢扫描
+type Sink interface {
+	// Entry 一条文件/目录记录，与写入输出文件的记录一致，按写入顺序调用
+	Entry(node *FileNode)
+	// Progress 扫描中定期（每 0.5 秒）报告的进度，遍历结束后进入其他阶段时也会报告一次（见 Progress.Phase）
+	Progress(p Progress)
+	// Error 扫描中遇到的错误，通常为 *ScanError；这些错误不会中止扫描，只计入错误数
+	Error(err error)
+	// Notice 提示信息，如增量扫描基准、从检查点恢复等
+	Notice(msg string)
+}
+
+// SinkFuncs 用函数实现 Sink，未设置的函数表示忽略对应的事件
+type SinkFuncs struct {
+	OnEntry    func(node *FileNode)
+	OnProgress func(p Progress)
+	OnError    func(err error)
+	OnNotice   func(msg string)
+}
+
+func (f SinkFuncs) Entry(node *FileNode) {
+	if f.OnEntry != nil {
+		f.OnEntry(node)
+	}
+}
+
+func (f SinkFuncs) Progress(p Progress) {
+	if f.OnProgress != nil {
+		f.OnProgress(p)
+	}
+}
+
+func (f SinkFuncs) Error(err error) {
+	if f.OnError != nil {
+		f.OnError(err)
+	}
+}
+
+func (f SinkFuncs) Notice(msg string) {
+	if f.OnNotice != nil {
+		f.OnNotice(msg)
+	}
+}
+
+// lockedSink 串行化对 Sink 的调用（worker、哈希 worker 和进度协程都会调用）
+type lockedSink struct {
+	mu   sync.Mutex
+	sink Sink
+}
+
+func (l *lockedSink) Entry(node *FileNode) {
+	l.mu.Lock()
+	l.sink.Entry(node)
+	l.mu.Unlock()
+}
+
+func (l *lockedSink) Progress(p Progress) {
+	l.mu.Lock()
+	l.sink.Progress(p)
+	l.mu.Unlock()
+}
+
+func (l *lockedSink) Error(err error) {
+	l.mu.Lock()
+	l.sink.Error(err)
+	l.mu.Unlock()
+}
+
+func (l *lockedSink) Notice(msg string) {
+	l.mu.Lock()
+	l.sink.Notice(msg)
+	l.mu.Unlock()
+}
+
+// ScanError 扫描单个路径时遇到的错误
+type ScanError struct {
+	Op    string // 出错的操作，如 "无法读取目录"
+	Path  string
+	IsDir bool  // Path 是正在扫描的目录本身（错误报告按该目录汇总，否则按所在目录汇总）
+	Err   error // 底层错误，可能为 nil
+}
+
+func (e *ScanError) Error() string {
+	if e.Err == nil {
+		return e.Op + " " + e.Path
+	}
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// 扫描阶段（Progress.Phase）
+const (
+	PhaseScanning    = "scanning"    // 遍历目录
+	PhaseSummarizing = "summarizing" // 遍历结束，写入目录汇总记录
+	PhaseSorting     = "sorting"     // 按路径排序输出文件
+)
+
+// Progress 扫描进度（JSON 字段名与 -progress 输出的进度事件一致）
+type Progress struct {
+	Phase         string           `json:"phase"`   // 扫描阶段
+	Elapsed       float64          `json:"elapsed"` // 已用时间（秒）
+	DirCount      int64            `json:"dirCount"`
+	FileCount     int64            `json:"fileCount"`
+	TotalDisk     int64            `json:"totalDisk"`            // 已扫描文件的磁盘占用
+	DiskUsedSize  int64            `json:"diskUsedSize"`         // 扫描根目录所在磁盘的已用空间，无法获取时为 0
+	Percentage    float64          `json:"percentage"`           // 按已用空间估算的进度，扫描过程中最多 99.9
+	ETA           float64          `json:"eta,omitempty"`        // 按估算的进度和已用时间推算的剩余时间（秒），无法估算时为 0
+	DirSpeed      float64          `json:"dirSpeed"`             // 每秒扫描的目录数
+	FileSpeed     float64          `json:"fileSpeed"`            // 每秒扫描的文件数
+	DiskSpeed     float64          `json:"diskSpeed"`            // 每秒扫描的磁盘占用
+	CurrentDir    string           `json:"currentDir,omitempty"` // 最近开始扫描的目录
+	QueueDepth    int64            `json:"queueDepth"`           // 等待扫描的目录数
+	ErrorCount    int64            `json:"errorCount"`
+	ErrorsByErrno map[string]int64 `json:"errorsByErrno,omitempty"` // 按 errno 分类的错误数
+	Workers       int              `json:"workers,omitempty"`       // 低负载模式下当前的 worker 上限
+}
+
+// Stats 扫描结束时的统计
+type Stats struct {
+	Duration      time.Duration    `json:"duration"`
+	Dirs          int64            `json:"dirs"`
+	Files         int64            `json:"files"`
+	TotalSize     int64            `json:"total_size"` // 文件逻辑大小总和
+	TotalDisk     int64            `json:"total_disk"` // 实际磁盘占用总和（硬链接只计一次）
+	Symlinks      int64            `json:"symlinks"`
+	BrokenLinks   int64            `json:"broken_links,omitempty"`
+	EscapingLinks int64            `json:"escaping_links,omitempty"`
+	Sparse        int64            `json:"sparse"`
+	Hardlinks     int64            `json:"hardlinks"`
+	DupDirs       int64            `json:"dup_dirs"`
+	ReusedDirs    int64            `json:"reused_dirs,omitempty"`
+	HashErrors    int64            `json:"hash_errors,omitempty"`
+	Excluded      int64            `json:"excluded"`
+	SkippedMounts int64            `json:"skipped_mounts,omitempty"`
+	DepthLimited  int64            `json:"depth_limited,omitempty"`
+	Errors        int64            `json:"errors"`
+	ErrorsByErrno map[string]int64 `json:"errors_by_errno,omitempty"` // 所有报告的错误（包括哈希失败等）按 errno 分类的数量
+	MinWorkers    int              `json:"min_workers,omitempty"`     // 低负载模式下 worker 上限的最小值
+	Roots         []RootTotal      `json:"roots,omitempty"`           // 扫描多个根目录时各根目录的合计
+}
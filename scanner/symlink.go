@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SymlinkInfo 报告中的一个符号链接
+type SymlinkInfo struct {
+	Path     string `json:"path"`
+	Target   string `json:"target"`             // 链接内容（readlink 的结果）
+	Resolved string `json:"resolved,omitempty"` // 解析后的真实路径（断开的链接为空）
+}
+
+// SymlinkReport 跟随符号链接时发现的断开链接和指向扫描根目录之外的链接
+type SymlinkReport struct {
+	RootPath string        `json:"root_path"`
+	Broken   []SymlinkInfo `json:"broken"`
+	Escaping []SymlinkInfo `json:"escaping"`
+}
+
+// followSymlink 跟随符号链接，返回目标的文件信息和解析后的真实路径
+// 断开的链接和指向扫描根目录之外的链接登记到批次中，随目录一起提交
+func (s *Scanner) followSymlink(b *dirBatch, path string) (os.FileInfo, string, bool) {
+	s.throttle.io()
+	info, err := os.Stat(path)
+	if err != nil {
+		target, _ := os.Readlink(path)
+		b.brokenLinks = append(b.brokenLinks, SymlinkInfo{Path: path, Target: target})
+		if s.options.Verbose {
+			s.sink.Notice(fmt.Sprintf("⚠️  断开的符号链接 %s -> %s", path, target))
+		}
+		return nil, "", false
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// 链接在两次调用之间发生了变化，按当前能获取到的信息处理
+		resolved = ""
+	}
+	if resolved != "" && !s.withinRoots(resolved) {
+		target, _ := os.Readlink(path)
+		b.escapingLinks = append(b.escapingLinks, SymlinkInfo{Path: path, Target: target, Resolved: resolved})
+	}
+	return info, resolved, true
+}
+
+// statPath 获取路径信息，跟随符号链接时路径本身可能是链接
+func (s *Scanner) statPath(path string) (os.FileInfo, error) {
+	if s.options.FollowSymlinks {
+		return os.Stat(path)
+	}
+	return os.Lstat(path)
+}
+
+// SymlinkReport 返回符号链接报告（开启 FollowSymlinks 时有效）
+func (s *Scanner) SymlinkReport() *SymlinkReport {
+	if !s.options.FollowSymlinks {
+		return nil
+	}
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+
+	report := &SymlinkReport{
+		RootPath: s.options.RootPath,
+		Broken:   append([]SymlinkInfo{}, s.links.Broken...),
+		Escaping: append([]SymlinkInfo{}, s.links.Escaping...),
+	}
+	sort.Slice(report.Broken, func(i, j int) bool { return report.Broken[i].Path < report.Broken[j].Path })
+	sort.Slice(report.Escaping, func(i, j int) bool { return report.Escaping[i].Path < report.Escaping[j].Path })
+	return report
+}
@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimiter 令牌桶，所有 worker 共享
+// 令牌不足时先预支再等待，保证长期速率不超过设定值
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒产生的令牌数
+	burst  float64 // 最多积攒的令牌数
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter 创建每秒 perSec 个令牌的限速器，perSec <= 0 时返回 nil（不限速）
+func newRateLimiter(perSec int) *rateLimiter {
+	if perSec <= 0 {
+		return nil
+	}
+	// 允许 0.1 秒的突发，避免每次调用都要等待
+	burst := float64(perSec) / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: float64(perSec), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait 取 n 个令牌，不足时等待（nil 时立即返回）
+func (l *rateLimiter) wait(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// workerGate 限制同时扫描目录的 worker 数，上限可以在扫描中调整
+type workerGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	max    int
+	active int
+}
+
+func newWorkerGate(max int) *workerGate {
+	g := &workerGate{limit: max, max: max}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *workerGate) acquire() {
+	g.mu.Lock()
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.active++
+	g.mu.Unlock()
+}
+
+func (g *workerGate) release() {
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+// setLimit 调整上限（1 到 max 之间）
+func (g *workerGate) setLimit(n int) {
+	if n < 1 {
+		n = 1
+	}
+	if n > g.max {
+		n = g.max
+	}
+	g.mu.Lock()
+	g.limit = n
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+// throttle 低负载扫描：限制 IO 调用和目录项的速率，-nice 时按系统负载自动减少 worker
+type throttle struct {
+	iops    *rateLimiter // ReadDir/Lstat/Stat 调用
+	entries *rateLimiter // 处理的目录项
+	gate    *workerGate  // nil 表示不自动调整 worker 数
+	limit   atomic.Int32 // 当前的 worker 上限（供进度显示）
+	minimum atomic.Int32 // 扫描过程中 worker 上限的最小值
+}
+
+// newThrottle 根据选项创建限速器，没有开启任何限速时返回 nil
+func newThrottle(options Options) *throttle {
+	if !options.Nice && options.MaxIOPS <= 0 && options.MaxEntriesPerSec <= 0 {
+		return nil
+	}
+	t := &throttle{
+		iops:    newRateLimiter(options.MaxIOPS),
+		entries: newRateLimiter(options.MaxEntriesPerSec),
+	}
+	if options.Nice {
+		t.gate = newWorkerGate(options.WorkerCount)
+	}
+	t.limit.Store(int32(options.WorkerCount))
+	t.minimum.Store(int32(options.WorkerCount))
+	return t
+}
+
+// io 在一次 ReadDir/Lstat/Stat 调用之前调用
+func (t *throttle) io() {
+	if t != nil {
+		t.iops.wait(1)
+	}
+}
+
+// entry 在处理一个目录项之前调用
+func (t *throttle) entry() {
+	if t != nil {
+		t.entries.wait(1)
+	}
+}
+
+// acquire/release 包围一次目录扫描
+func (t *throttle) acquire() {
+	if t != nil && t.gate != nil {
+		t.gate.acquire()
+	}
+}
+
+func (t *throttle) release() {
+	if t != nil && t.gate != nil {
+		t.gate.release()
+	}
+}
+
+// adapt 定期检查系统负载并调整 worker 上限，直到 done 被关闭
+// 负载平均值包含本进程的 worker，扣除正在扫描的 worker 后估算其他进程的负载：
+// 超过 CPU 数的 75% 时上限减半，低于 50% 时逐步恢复
+func (t *throttle) adapt(done chan bool) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	cpus := float64(runtime.NumCPU())
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			load, err := loadAverage()
+			if err != nil {
+				continue
+			}
+			t.gate.mu.Lock()
+			limit, active := t.gate.limit, t.gate.active
+			t.gate.mu.Unlock()
+
+			others := load - float64(active)
+			switch {
+			case others > cpus*0.75 && limit > 1:
+				limit /= 2
+			case others < cpus*0.5 && limit < t.gate.max:
+				limit++
+			default:
+				continue
+			}
+			t.gate.setLimit(limit)
+			t.limit.Store(int32(limit))
+			if int32(limit) < t.minimum.Load() {
+				t.minimum.Store(int32(limit))
+			}
+		}
+	}
+}
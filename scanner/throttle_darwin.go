@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadAverage 返回最近 1 分钟的系统负载（sysctl vm.loadavg: struct loadavg { fixpt_t ldavg[3]; long fscale; }）
+func loadAverage() (float64, error) {
+	raw, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil {
+		return 0, err
+	}
+	if len(raw) < 24 {
+		return 0, fmt.Errorf("vm.loadavg 长度异常: %d", len(raw))
+	}
+	load := binary.LittleEndian.Uint32(raw[0:4])
+	scale := binary.LittleEndian.Uint64(raw[16:24])
+	if scale == 0 {
+		return 0, fmt.Errorf("vm.loadavg 的 fscale 为 0")
+	}
+	return float64(load) / float64(scale), nil
+}
@@ -0,0 +1,21 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadAverage 返回最近 1 分钟的系统负载
+func loadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("无法解析 /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
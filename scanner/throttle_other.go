@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package scanner
+
+import "fmt"
+
+// loadAverage 其他平台暂不支持读取系统负载，不会自动调整 worker 数
+func loadAverage() (float64, error) {
+	return 0, fmt.Errorf("当前平台不支持读取系统负载")
+}
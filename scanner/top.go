@@ -0,0 +1,114 @@
+package scanner
+
+import "sync"
+
+// TopFile 排行中的一个文件
+type TopFile struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	DiskUsage int64  `json:"disk_usage"`
+	ModTime   int64  `json:"mod_time"`
+}
+
+// TopReport 最大文件/目录排行报告
+type TopReport struct {
+	RootPath         string     `json:"root_path"`
+	Limit            int        `json:"limit"`
+	OldMinSize       int64      `json:"old_min_size"` // "最旧的大文件" 的大小下限
+	LargestFiles     []TopFile  `json:"largest_files"`
+	LargestDirs      []DirTotal `json:"largest_dirs"`
+	OldestLargeFiles []TopFile  `json:"oldest_large_files"`
+}
+
+// topCollector 扫描过程中用有界堆收集排行，内存占用只与 N 相关
+// 目录大小来自 dirAggregator 的流式汇总，同样不需要文件树
+type topCollector struct {
+	rootPath   string
+	roots      map[string]struct{}
+	limit      int
+	oldMinSize int64
+
+	mu          sync.Mutex
+	largest     *TopN[TopFile]
+	oldestLarge *TopN[TopFile]
+	dirs        *TopN[DirTotal]
+}
+
+func newTopCollector(rootPaths []string, limit int, oldMinSize int64) *topCollector {
+	t := &topCollector{
+		rootPath:   rootPaths[0],
+		roots:      make(map[string]struct{}, len(rootPaths)),
+		limit:      limit,
+		oldMinSize: oldMinSize,
+		largest: NewTopN(limit, func(a, b TopFile) bool {
+			return a.DiskUsage < b.DiskUsage
+		}),
+		// 越旧越"大"
+		oldestLarge: NewTopN(limit, func(a, b TopFile) bool {
+			return a.ModTime > b.ModTime
+		}),
+		dirs: NewTopN(limit, func(a, b DirTotal) bool {
+			return a.DiskUsage < b.DiskUsage
+		}),
+	}
+	for _, path := range rootPaths {
+		t.roots[path] = struct{}{}
+	}
+	return t
+}
+
+// addDir 登记一个完成汇总的目录（根目录就是扫描总计，不参与排行）
+func (t *topCollector) addDir(d DirTotal) {
+	if _, ok := t.roots[d.Path]; ok {
+		return
+	}
+	t.mu.Lock()
+	t.dirs.Push(d)
+	t.mu.Unlock()
+}
+
+// addFile 登记一个文件（重复的硬链接不占用额外空间，不参与排行）
+func (t *topCollector) addFile(path string, size, disk, modTime int64, isHardlink bool) {
+	if isHardlink {
+		return
+	}
+	f := TopFile{Path: path, Size: size, DiskUsage: disk, ModTime: modTime}
+	t.mu.Lock()
+	t.largest.Push(f)
+	if size >= t.oldMinSize {
+		t.oldestLarge.Push(f)
+	}
+	t.mu.Unlock()
+}
+
+// commit 登记一个已提交目录中的文件
+func (t *topCollector) commit(b *dirBatch) {
+	for _, node := range b.records {
+		if !node.IsDir {
+			t.addFile(node.Path, node.Size, node.DiskUsage, node.ModTime, node.IsHardlink)
+		}
+	}
+}
+
+// report 生成排行报告（扫描结束后调用）
+func (t *topCollector) report() *TopReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &TopReport{
+		RootPath:         t.rootPath,
+		Limit:            t.limit,
+		OldMinSize:       t.oldMinSize,
+		LargestFiles:     t.largest.Sorted(),
+		LargestDirs:      t.dirs.Sorted(),
+		OldestLargeFiles: t.oldestLarge.Sorted(),
+	}
+}
+
+// TopReport 返回最大文件/目录排行（开启 TopN 时有效）
+func (s *Scanner) TopReport() *TopReport {
+	if s.top == nil {
+		return nil
+	}
+	return s.top.report()
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopN 保留最大的 N 个元素，内部是容量固定的最小堆，内存占用与 N 相关而与数据量无关
+// 非并发安全，需要由调用方加锁
+type TopN[T any] struct {
+	h topHeap[T]
+}
+
+// NewTopN 创建 TopN，less(a, b) 为 true 表示 a 比 b 小
+func NewTopN[T any](limit int, less func(a, b T) bool) *TopN[T] {
+	return &TopN[T]{h: topHeap[T]{limit: limit, less: less}}
+}
+
+// Push 加入一个元素，超出容量时淘汰最小的元素
+func (t *TopN[T]) Push(item T) {
+	if t.h.limit <= 0 {
+		return
+	}
+	if len(t.h.items) < t.h.limit {
+		heap.Push(&t.h, item)
+		return
+	}
+	if t.h.less(t.h.items[0], item) {
+		t.h.items[0] = item
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// Sorted 返回从大到小排列的结果
+func (t *TopN[T]) Sorted() []T {
+	result := make([]T, len(t.h.items))
+	copy(result, t.h.items)
+	sort.Slice(result, func(i, j int) bool { return t.h.less(result[j], result[i]) })
+	return result
+}
+
+// topHeap 实现 heap.Interface 的最小堆
+type topHeap[T any] struct {
+	limit int
+	less  func(a, b T) bool
+	items []T
+}
+
+func (h topHeap[T]) Len() int            { return len(h.items) }
+func (h topHeap[T]) Less(i, j int) bool  { return h.less(h.items[i], h.items[j]) }
+func (h topHeap[T]) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *topHeap[T]) Push(x interface{}) { h.items = append(h.items, x.(T)) }
+func (h *topHeap[T]) Pop() interface{} {
+	old := h.items
+	item := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return item
+}
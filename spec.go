@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zjmainstay/mac-file-search/scanner"
+)
+
+// scanSpec 扫描参数：命令行参数解析到这里，也可以用 -spec 从 JSON 文件（或标准输入）读取
+// 扫描配置中的根目录、排除路径等都是 JSON 数组，不需要拼接命令行，路径中有逗号、引号或空格也不受影响
+type scanSpec struct {
+	// 扫描范围
+	Roots          pathList `json:"roots"`               // 根目录（-path）
+	RootsFile      string   `json:"roots_file"`          // 根目录列表文件（-roots-file）
+	Exclude        listArg  `json:"exclude"`             // 排除的路径（-exclude）
+	ExcludeGlobs   listArg  `json:"exclude_glob"`        // 排除的 glob 模式（-exclude-glob）
+	IgnoreFiles    bool     `json:"ignore_files"`        // 遵循 .gitignore 等忽略文件（-ignore-files）
+	FollowSymlinks bool     `json:"follow_symlinks"`     // 跟随符号链接（-follow-symlinks）
+	OneFileSystem  bool     `json:"xdev"`                // 只扫描根目录所在的文件系统（-xdev）
+	MaxDepth       int      `json:"max_depth"`           // 最大扫描深度（-max-depth）
+	OnlyFsTypes    listArg  `json:"fstype"`              // 只进入这些文件系统类型（-fstype）
+	SkipFsTypes    listArg  `json:"skip_fstype"`         // 跳过这些文件系统类型（-skip-fstype）
+	SinceOutput    string   `json:"since_output"`        // 增量扫描的上一次输出（-since-output）
+	Resume         bool     `json:"resume"`              // 从检查点恢复（-resume）
+	Workers        int      `json:"workers"`             // 并发数（-workers）
+	Order          string   `json:"order"`               // 遍历顺序（-order）
+	MaxOpenDirs    int      `json:"max_open_dirs"`       // 同时打开的目录上限（-max-open-dirs）
+	Nice           bool     `json:"nice"`                // 低负载模式（-nice）
+	MaxIOPS        int      `json:"max_iops"`            // IO 速率上限（-max-iops）
+	MaxEntries     int      `json:"max_entries_per_sec"` // 目录项速率上限（-max-entries-per-sec）
+
+	// 筛选条件
+	MinSize     sizeArg `json:"min"`         // 最小文件大小（-min）
+	MaxSize     sizeArg `json:"max"`         // 最大文件大小（-max）
+	IncludeExts listArg `json:"include_ext"` // 只包含的扩展名（-include-ext）
+	ExcludeExts listArg `json:"exclude_ext"` // 排除的扩展名（-exclude-ext）
+	NamePattern string  `json:"name"`        // 文件名正则表达式（-name）
+	Meta        bool    `json:"meta"`        // 记录元数据（-meta）
+	Users       listArg `json:"user"`        // 只包含这些用户的文件（-user）
+	Groups      listArg `json:"group"`       // 只包含这些用户组的文件（-group）
+	Perm        string  `json:"perm"`        // 权限条件（-perm）
+	Newer       string  `json:"newer"`       // 时间晚于（-newer）
+	Older       string  `json:"older"`       // 时间早于（-older）
+
+	// 内容哈希
+	HashAlgo    string  `json:"hash"`         // 哈希算法（-hash）
+	HashMax     sizeArg `json:"hash_max"`     // 计算哈希的大小上限（-hash-max）
+	HashLarge   string  `json:"hash_large"`   // 超过上限的文件（-hash-large）
+	HashWorkers int     `json:"hash_workers"` // 哈希并发数（-hash-workers）
+
+	// 输出
+	OutputFile         string      `json:"output"`              // 输出文件（-output）
+	Format             string      `json:"format"`              // 输出格式（-format）
+	Template           string      `json:"printf"`              // 输出模板（-printf）
+	SortedOutput       bool        `json:"sorted"`              // 按路径排序（-sorted）
+	DirSummary         bool        `json:"dir_summary"`         // 目录汇总记录（-dir-summary）
+	CheckpointInterval durationArg `json:"checkpoint_interval"` // 检查点间隔（-checkpoint-interval）
+	ErrorsFile         string      `json:"errors_file"`         // 错误记录文件（-errors-file）
+	Progress           string      `json:"progress"`            // 进度事件流（-progress）
+	ProgressFile       string      `json:"progress_file"`       // 进度文件（-progress-file）
+
+	// 报告
+	ShowErrors    bool    `json:"errors"`         // 显示错误详情（-errors）
+	ShowTree      bool    `json:"tree"`           // 显示文件树（-tree）
+	TreeDepth     int     `json:"depth"`          // 文件树显示深度（-depth）
+	TopCount      int     `json:"top"`            // 排行条数（-top）
+	TopOutput     string  `json:"top_output"`     // 排行报告（-top-output）
+	TopOldMin     sizeArg `json:"top_old_min"`    // 最旧的大文件的大小下限（-top-old-min）
+	SymlinkReport string  `json:"symlink_report"` // 符号链接报告（-symlink-report）
+	FindDupes     bool    `json:"dupes"`          // 查找重复文件（-dupes）
+	DupesOutput   string  `json:"dupes_output"`   // 重复文件报告（-dupes-output）
+}
+
+// listArg 列表参数：命令行中用逗号分隔（也可以重复指定），扫描配置中为 JSON 数组
+type listArg []string
+
+func (l *listArg) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listArg) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// sizeArg 大小参数：100M、1.5G 等，扫描配置中也可以直接写字节数
+type sizeArg string
+
+func (a *sizeArg) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, (*string)(a))
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("大小应为字节数或 \"100M\" 这样的字符串: %s", data)
+	}
+	*a = sizeArg(strconv.FormatInt(n, 10))
+	return nil
+}
+
+// durationArg 时长参数，扫描配置中写作 "30s"、"5m" 这样的字符串
+type durationArg time.Duration
+
+func (d *durationArg) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("时长应为 \"30s\"、\"5m\" 这样的字符串: %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationArg(v)
+	return nil
+}
+
+// loadSpec 读取扫描配置（path 为 - 时从标准输入读取），配置中没有的键保留 sp 中的默认值
+// 未知的键、类型不符和多余的内容都会报错，拼错的键不会被静默忽略
+func loadSpec(path string, sp *scanSpec) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("无法读取扫描配置: %v", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(sp); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			if typeErr.Field == "" {
+				return fmt.Errorf("扫描配置有误: 应为 JSON 对象")
+			}
+			return fmt.Errorf("扫描配置有误: %s 应为 %v 类型", typeErr.Field, typeErr.Type)
+		}
+		if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("扫描配置有误: 未知的键 %s", key)
+		}
+		return fmt.Errorf("扫描配置有误: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("扫描配置有误: 只能包含一个 JSON 对象")
+	}
+	if len(sp.Roots) == 0 && sp.RootsFile == "" {
+		return fmt.Errorf("扫描配置有误: 缺少 roots")
+	}
+	if err := sp.validate(); err != nil {
+		return fmt.Errorf("扫描配置有误: %v", err)
+	}
+	return nil
+}
+
+// validate 检查扫描配置中参数的取值（输出格式、遍历顺序、哈希算法等由扫描器检查）
+func (sp *scanSpec) validate() error {
+	lists := []struct {
+		name  string
+		items []string
+	}{
+		{"roots", sp.Roots},
+		{"exclude", sp.Exclude},
+		{"exclude_glob", sp.ExcludeGlobs},
+		{"fstype", sp.OnlyFsTypes},
+		{"skip_fstype", sp.SkipFsTypes},
+		{"include_ext", sp.IncludeExts},
+		{"exclude_ext", sp.ExcludeExts},
+		{"user", sp.Users},
+		{"group", sp.Groups},
+	}
+	for _, l := range lists {
+		for i, item := range l.items {
+			if strings.TrimSpace(item) == "" {
+				return fmt.Errorf("%s[%d] 为空", l.name, i)
+			}
+			if strings.IndexByte(item, 0) >= 0 {
+				return fmt.Errorf("%s[%d] 包含 NUL 字符", l.name, i)
+			}
+		}
+	}
+
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"workers", sp.Workers},
+		{"max_open_dirs", sp.MaxOpenDirs},
+		{"max_depth", sp.MaxDepth},
+		{"max_iops", sp.MaxIOPS},
+		{"max_entries_per_sec", sp.MaxEntries},
+		{"hash_workers", sp.HashWorkers},
+		{"depth", sp.TreeDepth},
+		{"top", sp.TopCount},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			return fmt.Errorf("%s 不能为负数: %d", c.name, c.value)
+		}
+	}
+	if sp.CheckpointInterval < 0 {
+		return fmt.Errorf("checkpoint_interval 不能为负数")
+	}
+	return nil
+}
+
+// options 转换为扫描器选项
+func (sp *scanSpec) options(sink scanner.Sink) (scanner.Options, error) {
+	roots := append([]string(nil), sp.Roots...)
+	if sp.RootsFile != "" {
+		fileRoots, err := readRootsFile(sp.RootsFile)
+		if err != nil {
+			return scanner.Options{}, err
+		}
+		roots = append(roots, fileRoots...)
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	minSize, err := scanner.ParseSize(string(sp.MinSize))
+	if err != nil {
+		return scanner.Options{}, fmt.Errorf("最小文件大小参数错误: %v", err)
+	}
+	maxSize, err := scanner.ParseSize(string(sp.MaxSize))
+	if err != nil {
+		return scanner.Options{}, fmt.Errorf("最大文件大小参数错误: %v", err)
+	}
+	hashMax, err := scanner.ParseSize(string(sp.HashMax))
+	if err != nil {
+		return scanner.Options{}, fmt.Errorf("哈希大小上限参数错误: %v", err)
+	}
+	topOldMin, err := scanner.ParseSize(string(sp.TopOldMin))
+	if err != nil {
+		return scanner.Options{}, fmt.Errorf("-top-old-min 参数错误: %v", err)
+	}
+
+	return scanner.Options{
+		RootPaths:          roots,
+		MinSize:            minSize,
+		MaxSize:            maxSize,
+		WorkerCount:        sp.Workers,
+		Order:              sp.Order,
+		MaxOpenDirs:        sp.MaxOpenDirs,
+		OutputFile:         sp.OutputFile,
+		ErrorsFile:         sp.ErrorsFile,
+		Format:             sp.Format,
+		Template:           sp.Template,
+		Verbose:            sp.ShowErrors,
+		ExcludePaths:       resolveExcludePaths(sp.Exclude),
+		ExcludeGlobs:       sp.ExcludeGlobs,
+		IgnoreFiles:        sp.IgnoreFiles,
+		FollowSymlinks:     sp.FollowSymlinks,
+		OneFileSystem:      sp.OneFileSystem,
+		MaxDepth:           sp.MaxDepth,
+		OnlyFsTypes:        sp.OnlyFsTypes,
+		SkipFsTypes:        sp.SkipFsTypes,
+		IncludeExts:        normalizeExts(sp.IncludeExts),
+		ExcludeExts:        normalizeExts(sp.ExcludeExts),
+		NamePattern:        sp.NamePattern,
+		Meta:               sp.Meta,
+		Users:              sp.Users,
+		Groups:             sp.Groups,
+		Perm:               sp.Perm,
+		Newer:              sp.Newer,
+		Older:              sp.Older,
+		SinceOutput:        sp.SinceOutput,
+		Resume:             sp.Resume,
+		CheckpointInterval: time.Duration(sp.CheckpointInterval),
+		FindDupes:          sp.FindDupes,
+		HashAlgo:           sp.HashAlgo,
+		HashMaxSize:        hashMax,
+		HashLarge:          sp.HashLarge,
+		HashWorkers:        sp.HashWorkers,
+		SortedOutput:       sp.SortedOutput,
+		DirSummary:         sp.DirSummary,
+		BuildTree:          sp.ShowTree,
+		TopN:               sp.TopCount,
+		TopOldMinSize:      topOldMin,
+		Nice:               sp.Nice,
+		MaxIOPS:            sp.MaxIOPS,
+		MaxEntriesPerSec:   sp.MaxEntries,
+		Sink:               sink,
+	}, nil
+}